@@ -0,0 +1,91 @@
+// Command ansi2html converts colored logs of ColorHandler to HTML or plain text.
+//
+//	ansi2html [-strip] [-page] [FILE...]
+//
+// It reads stdin if no FILE is given.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shu-go/shandler/color"
+)
+
+const pageHeader = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"></head>
+<body>
+<pre>`
+
+const pageFooter = `</pre>
+</body>
+</html>
+`
+
+func main() {
+	strip := flag.Bool("strip", false, "write plain text instead of HTML")
+	page := flag.Bool("page", false, "write a whole HTML page")
+	flag.Parse()
+
+	if err := run(os.Stdout, flag.Args(), *strip, *page); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(out io.Writer, files []string, strip, page bool) error {
+	bw := bufio.NewWriter(out)
+
+	var w io.WriteCloser
+	if strip {
+		w = nopCloser{color.Strip(bw)}
+	} else {
+		w = color.ANSIToHTML(bw)
+		if page {
+			bw.WriteString(pageHeader)
+		}
+	}
+
+	if len(files) == 0 {
+		if _, err := io.Copy(w, os.Stdin); err != nil {
+			return err
+		}
+	}
+	for _, name := range files {
+		if err := copyFile(w, name); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if !strip && page {
+		bw.WriteString(pageFooter)
+	}
+
+	return bw.Flush()
+}
+
+func copyFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shu-go/gotwant"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "1.log")
+	file2 := filepath.Join(dir, "2.log")
+	// a sequence split across files
+	if err := os.WriteFile(file1, []byte("INFO \x1b[1;31m<msg>\x1b"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file2, []byte("[0m k=v\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	test := func(t *testing.T, strip, page bool, want string) {
+		t.Helper()
+
+		out := &bytes.Buffer{}
+		gotwant.TestError(t, run(out, []string{file1, file2}, strip, page), nil)
+		gotwant.Test(t, out.String(), want)
+	}
+
+	html := `INFO <span style="font-weight:bold;color:#cd0000">&lt;msg&gt;</span> k=v` + "\n"
+	test(t, false, false, html)
+	test(t, false, true, pageHeader+html+pageFooter)
+	test(t, true, false, "INFO <msg> k=v\n")
+	test(t, true, true, "INFO <msg> k=v\n")
+
+	t.Run("Error", func(t *testing.T) {
+		err := run(&bytes.Buffer{}, []string{filepath.Join(dir, "nonexistent.log")}, false, false)
+		gotwant.TestExpr(t, err, err != nil && strings.Contains(err.Error(), "nonexistent.log"))
+	})
+}
@@ -0,0 +1,237 @@
+package color
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Strip returns a writer that writes to w with escape sequences removed.
+//
+// Sequences split across writes are removed too.
+func Strip(w io.Writer) io.Writer {
+	return &stripWriter{w: w}
+}
+
+type stripWriter struct {
+	w      io.Writer
+	parser ansiParser
+	buf    []byte
+}
+
+func (s *stripWriter) Write(p []byte) (int, error) {
+	s.buf = s.buf[:0]
+	s.parser.parse(p, func(text []byte) {
+		s.buf = append(s.buf, text...)
+	}, nil)
+
+	if len(s.buf) != 0 {
+		if _, err := s.w.Write(s.buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// ANSIToHTML returns a writer that writes to w
+// with SGR sequences (colors and styles) converted to <span style="...">.
+// Texts are escaped. The output is to be put in <pre>.
+//
+// Close closes the last <span>, not w.
+func ANSIToHTML(w io.Writer) io.WriteCloser {
+	return &htmlWriter{w: w}
+}
+
+type htmlWriter struct {
+	w      io.Writer
+	parser ansiParser
+	buf    []byte
+
+	state sgrState
+	open  bool
+}
+
+func (h *htmlWriter) Write(p []byte) (int, error) {
+	h.buf = h.buf[:0]
+	h.parser.parse(p, func(text []byte) {
+		h.buf = appendHTMLEscape(h.buf, string(text))
+	}, func(params []int) {
+		h.state.apply(params)
+
+		if h.open {
+			h.buf = append(h.buf, "</span>"...)
+			h.open = false
+		}
+		if style := h.state.css(); style != "" {
+			h.buf = (&HTMLColor{Style: style}).AppendFormat(h.buf)
+			h.open = true
+		}
+	})
+
+	if len(h.buf) != 0 {
+		if _, err := h.w.Write(h.buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (h *htmlWriter) Close() error {
+	if !h.open {
+		return nil
+	}
+
+	h.open = false
+	h.state = sgrState{}
+	_, err := io.WriteString(h.w, "</span>")
+	return err
+}
+
+// ansiParser splits bytes into texts and escape sequences,
+// keeping an unfinished sequence for the next parse.
+type ansiParser struct {
+	state  int
+	params []byte
+}
+
+const (
+	ansiText = iota
+	ansiEsc
+	ansiCSI
+)
+
+// too long parameters are ignored
+const maxParamsLen = 64
+
+// calls text for texts, and sgr (if not nil) for SGR sequences.
+// Other sequences are dropped.
+func (a *ansiParser) parse(p []byte, text func([]byte), sgr func([]int)) {
+	start := 0
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+
+		switch a.state {
+		case ansiText:
+			if c == '\x1b' {
+				if start < i {
+					text(p[start:i])
+				}
+				a.state = ansiEsc
+			}
+
+		case ansiEsc:
+			switch c {
+			case '[':
+				a.state = ansiCSI
+				a.params = a.params[:0]
+			case '\x1b':
+				// the lone ESC is dropped, and this one starts a sequence
+			default:
+				// a 2-byte sequence
+				a.state = ansiText
+				start = i + 1
+			}
+
+		case ansiCSI:
+			switch {
+			case c == '\x1b':
+				// cancels the sequence and starts a new one
+				a.state = ansiEsc
+			case c >= 0x40 && c <= 0x7e:
+				if c == 'm' && sgr != nil && len(a.params) <= maxParamsLen {
+					sgr(parseSGR(string(a.params)))
+				}
+				a.state = ansiText
+				start = i + 1
+			case len(a.params) <= maxParamsLen:
+				a.params = append(a.params, c)
+			}
+		}
+	}
+
+	if a.state == ansiText && start < len(p) {
+		text(p[start:])
+	}
+}
+
+// "" is 0.
+func parseSGR(params string) []int {
+	var ns []int
+	for _, s := range strings.Split(params, ";") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			n = 0
+		}
+		ns = append(ns, n)
+	}
+	return ns
+}
+
+// sgrState is the current styles of SGR sequences.
+type sgrState struct {
+	attrs  [10]bool // indexed by color.Bold ... color.CrossedOut
+	fg, bg ColorSpec
+}
+
+func (s *sgrState) apply(params []int) {
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == int(color.Reset):
+			*s = sgrState{}
+		case p >= int(color.Bold) && p <= int(color.CrossedOut):
+			s.attrs[p] = true
+		case p == int(color.ResetBold):
+			// also faint
+			s.attrs[color.Bold] = false
+			s.attrs[color.Faint] = false
+		case p >= int(color.ResetItalic) && p <= int(color.ResetCrossedOut):
+			s.attrs[p-20] = false
+			if p == int(color.ResetBlinking) {
+				s.attrs[color.BlinkRapid] = false
+			}
+		case p >= int(color.FgBlack) && p <= int(color.FgWhite):
+			s.fg = Index256(uint8(p - int(color.FgBlack)))
+		case p >= int(color.FgHiBlack) && p <= int(color.FgHiWhite):
+			s.fg = Index256(uint8(p-int(color.FgHiBlack)) + 8)
+		case p >= int(color.BgBlack) && p <= int(color.BgWhite):
+			s.bg = Index256(uint8(p - int(color.BgBlack)))
+		case p >= int(color.BgHiBlack) && p <= int(color.BgHiWhite):
+			s.bg = Index256(uint8(p-int(color.BgHiBlack)) + 8)
+		case p == 39:
+			s.fg = ColorSpec{}
+		case p == 49:
+			s.bg = ColorSpec{}
+		case p == 38 || p == 48:
+			// 38;5;n or 38;2;r;g;b
+			var spec ColorSpec
+			if i+2 < len(params) && params[i+1] == 5 {
+				spec = Index256(uint8(params[i+2]))
+				i += 2
+			} else if i+4 < len(params) && params[i+1] == 2 {
+				spec = RGB(uint8(params[i+2]), uint8(params[i+3]), uint8(params[i+4]))
+				i += 4
+			}
+			if p == 38 {
+				s.fg = spec
+			} else {
+				s.bg = spec
+			}
+		}
+	}
+}
+
+func (s *sgrState) css() string {
+	c := ExtColor{Fg: s.fg, Bg: s.bg}
+	for a, on := range s.attrs {
+		if on {
+			c.Attrs = append(c.Attrs, color.Attribute(a))
+		}
+	}
+	if s.attrs[color.ReverseVideo] {
+		c.Fg, c.Bg = c.Bg, c.Fg
+	}
+	return cssStyle(&c)
+}
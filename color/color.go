@@ -2,15 +2,13 @@ package color
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"log"
 	"log/slog"
-	"path/filepath"
-	"runtime"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 type ColorHandler struct {
@@ -20,14 +18,19 @@ type ColorHandler struct {
 
 	attrs  []byte
 	groups []string
+	// number of groups whose headers are in attrs (Multiline)
+	openGroups int
 
 	mu *sync.Mutex
 	w  io.Writer
 
-	cacheFlagDate   bool
-	cacheFlagTime   bool
-	cacheFlagMicro  bool
-	cacheTimeFormat string
+	// for TimeRelative
+	start time.Time
+	// for WidthTerminal
+	term *termWidth
+
+	// escapes text for HTML (NewHTMLHandler)
+	html bool
 }
 
 type HandlerOptions struct {
@@ -36,7 +39,49 @@ type HandlerOptions struct {
 	Level       slog.Leveler
 	ReplaceAttr func([]string, slog.Attr) slog.Attr
 
+	// Compat prints the same format as slog.TextHandler (time, level, source, msg and attrs).
+	// The time is printed in RFC3339 with milliseconds unless TimeNone.
 	Compat bool
+
+	// TimeFormat is a layout for time.Time.Format (such as time.RFC3339),
+	// or TimeUnixMilli, TimeRelative, TimeNone.
+	// Default is TimeDefault.
+	TimeFormat string
+	// TimeUTC formats time in UTC.
+	TimeUTC bool
+
+	// SourceFormat is how the source is printed if AddSource.
+	SourceFormat SourceFormat
+
+	// FollowLogFlags decides time and source by log.Flags() for each record,
+	// instead of TimeFormat, TimeUTC and SourceFormat.
+	// The source is printed if AddSource or log.Lshortfile or log.Llongfile.
+	FollowLogFlags bool
+
+	// Multiline renders each attr on its own line, groups as indented blocks,
+	// and maps, slices and structs as indented JSON.
+	// It is ignored if Compat.
+	Multiline bool
+
+	// ColorMode decides whether to color by the writer given to NewHandler.
+	// Default is ColorAuto.
+	ColorMode ColorMode
+
+	// InlineStyle makes NewHTMLHandler write style attributes instead of classes.
+	InlineStyle bool
+
+	// Layout options below are ignored if Compat.
+
+	// LevelFormat is how the level is printed.
+	LevelFormat LevelFormat
+	// MessageWidth pads the message so that attrs start in the same column.
+	// It is ignored if Multiline.
+	MessageWidth int
+	// Width limits the width of a line, such as 120 or WidthTerminal.
+	// Attrs over Width are truncated with "…", or wrapped if Wrap.
+	// It is ignored if Multiline.
+	Width int
+	Wrap  bool
 }
 
 func NewHandler(w io.Writer, opts *HandlerOptions, scheme *Scheme) *ColorHandler {
@@ -50,6 +95,14 @@ func NewHandler(w io.Writer, opts *HandlerOptions, scheme *Scheme) *ColorHandler
 	if h.opts.Level == nil {
 		h.opts.Level = slog.LevelInfo
 	}
+	if h.opts.TimeFormat == "" {
+		h.opts.TimeFormat = TimeDefault
+	}
+
+	h.start = time.Now()
+	if h.opts.Width == WidthTerminal {
+		h.term = newTermWidth(w)
+	}
 
 	if scheme != nil {
 		h.scheme = *scheme
@@ -57,6 +110,7 @@ func NewHandler(w io.Writer, opts *HandlerOptions, scheme *Scheme) *ColorHandler
 		scheme = DefaultDarkScheme()
 		h.scheme = *scheme
 	}
+	h.scheme = h.scheme.applyColorMode(colorEnabled(w, h.opts.ColorMode), h.opts.ColorMode == ColorAlways)
 
 	return h
 }
@@ -68,11 +122,20 @@ func (h *ColorHandler) Enabled(ctx context.Context, level slog.Level) bool {
 func (h *ColorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	h2 := h.clone()
 
-	prefix := strings.Join(h.groups, ".")
+	if h.multiline() {
+		var block []byte
+		for _, a := range attrs {
+			block = h.appendAttrBlock(block, h.groups, a)
+		}
+		if len(block) != 0 {
+			h2.attrs = h.appendGroupHeaders(h2.attrs)
+			h2.attrs = append(h2.attrs, block...)
+			h2.openGroups = len(h.groups)
+		}
+		return h2
+	}
 
-	pk := h.scheme.AttrKeyPrinter()
-	pv := h.scheme.AttrValuePrinter()
-	pn := h.scheme.BasePrinter()
+	prefix := strings.Join(h.groups, ".")
 
 	h2attrs := h2.attrs
 	for i := 0; i < len(attrs); i++ {
@@ -80,7 +143,7 @@ func (h *ColorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 			continue
 		}
 
-		h2attrs = appendAttr(h2attrs, prefix, attrs[i], h.opts.ReplaceAttr, h.groups, pk, pv, pn)
+		h2attrs = h.appendAttr(h2attrs, prefix, attrs[i], h.groups)
 	}
 
 	h2.attrs = h2attrs
@@ -102,129 +165,62 @@ func (h *ColorHandler) Handle(ctx context.Context, r slog.Record) error {
 	buf := *pbuf
 	buf = buf[:0]
 
-	flags := log.Flags()
-	flagdate := flags&log.Ldate != 0
-	flagtime := flags&log.Ltime != 0
-	flagmicro := flags&log.Lmicroseconds != 0
-	flagshortfile := flags&log.Lshortfile != 0
-	flaglongfile := flags&log.Llongfile != 0
+	flags := 0
+	if h.opts.FollowLogFlags {
+		flags = log.Flags()
+	}
 
 	level := r.Level.Level()
 
-	if !r.Time.IsZero() {
-		var format string
-
-		if flagdate == h.cacheFlagDate && flagtime == h.cacheFlagTime && flagmicro == h.cacheFlagMicro {
-			format = h.cacheTimeFormat
-		} else {
-			if flagdate {
-				format = "2006/01/02"
-			}
-			if flagtime || flagmicro {
-				if format != "" {
-					format += " "
-				}
-
-				format += "15:04:05"
-				if flagmicro {
-					format += ".000000"
-				}
-			}
-			h.cacheFlagDate = flagdate
-			h.cacheFlagTime = flagtime
-			h.cacheFlagMicro = flagmicro
-			h.cacheTimeFormat = format
-		}
+	// ReplaceAttr is applied to built-in attrs with no groups, as slog.TextHandler.
 
-		if format != "" {
-			if h.opts.Compat {
-				buf = append(buf, `time="`...)
-			}
-
-			tm := h.scheme.TimePrinter()
-			buf = tm.AppendFormat(buf)
-			if flags&log.LUTC != 0 {
-				buf = r.Time.UTC().AppendFormat(buf, format)
-			} else {
-				buf = r.Time.AppendFormat(buf, format)
-			}
-			buf = tm.AppendUnformat(buf)
-
-			if h.opts.Compat {
-				buf = append(buf, '"')
-			}
-			buf = append(buf, ' ')
-		}
+	if !r.Time.IsZero() {
+		buf = h.appendTime(buf, r.Time, flags)
 	}
 
-	if (h.opts.AddSource || flaglongfile || flagshortfile) && r.PC != 0 {
-		fs := runtime.CallersFrames([]uintptr{r.PC})
-		f, _ := fs.Next()
-
-		if h.opts.Compat {
-			buf = append(buf, `source="`...)
-		}
-
-		src := h.scheme.SourcePrinter()
-		buf = src.AppendFormat(buf)
-		if h.opts.AddSource || flaglongfile {
-			buf = append(buf, f.File...)
-			buf = append(buf, ':')
-			buf = fmt.Appendf(buf, "%d", f.Line)
-			if !h.opts.Compat {
-				buf = append(buf, ':')
-				buf = append(buf, ' ')
-			}
-		} else {
-			buf = append(buf, filepath.Base(f.File)...)
-			buf = append(buf, ':')
-			buf = fmt.Appendf(buf, "%d", f.Line)
-			if !h.opts.Compat {
-				buf = append(buf, ':')
-				buf = append(buf, ' ')
-			}
-		}
-		buf = src.AppendUnformat(buf)
-
-		if h.opts.Compat {
-			buf = append(buf, '"')
-		}
+	// Compat: time, level, source, msg as slog.TextHandler
+	if r.PC != 0 && !h.opts.Compat {
+		buf = h.appendSource(buf, r.PC, flags)
 	}
 
-	lvl := h.scheme.LevelPrinter(level)
-	if h.opts.Compat {
-		buf = append(buf, "level="...)
-	}
-	buf = lvl.AppendFormat(buf)
-	buf = append(buf, level.String()...)
-	buf = lvl.AppendUnformat(buf)
+	buf = h.appendLevel(buf, level)
 
-	buf = append(buf, ' ')
+	if r.PC != 0 && h.opts.Compat {
+		buf = h.appendSource(buf, r.PC, flags)
+	}
 
-	msg := h.scheme.MessagePrinter()
-	if h.opts.Compat {
-		buf = append(buf, "msg="...)
+	msgStart := len(buf)
+	buf = h.appendMessage(buf, r.Message)
+	if len(h.attrs) != 0 || r.NumAttrs() != 0 {
+		buf = h.padMessage(buf, msgStart)
 	}
-	buf = msg.AppendFormat(buf)
-	buf = append(buf, r.Message...)
-	buf = msg.AppendUnformat(buf)
 
+	attrsStart := len(buf)
 	buf = append(buf, h.attrs...)
 
-	pk := h.scheme.AttrKeyPrinter()
-	pv := h.scheme.AttrValuePrinter()
-	pn := h.scheme.BasePrinter()
-
-	r.Attrs(func(a slog.Attr) bool {
-		if a.Equal(slog.Attr{}) {
+	if h.multiline() {
+		var block []byte
+		r.Attrs(func(a slog.Attr) bool {
+			block = h.appendAttrBlock(block, h.groups, a)
 			return true
+		})
+		if len(block) != 0 {
+			buf = h.appendGroupHeaders(buf)
+			buf = append(buf, block...)
 		}
+	} else {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Equal(slog.Attr{}) {
+				return true
+			}
 
-		prefix := strings.Join(h.groups, ".")
-		buf = appendAttr(buf, prefix, a, h.opts.ReplaceAttr, h.groups, pk, pv, pn)
+			prefix := strings.Join(h.groups, ".")
+			buf = h.appendAttr(buf, prefix, a, h.groups)
 
-		return true
-	})
+			return true
+		})
+	}
+	buf = h.fitWidth(buf, attrsStart, h.lineWidth())
 	buf = append(buf, '\n')
 
 	h.mu.Lock()
@@ -237,39 +233,40 @@ func (h *ColorHandler) Handle(ctx context.Context, r slog.Record) error {
 	return err
 }
 
-func appendQuote(b []byte, s string) []byte {
-	if strings.ContainsAny(s, " \t\"") {
-		b = append(b, '"')
-		b = append(b, s...)
-		b = append(b, '"')
-		return b
-	}
-	return append(b, s...)
-}
-
 func (h ColorHandler) clone() *ColorHandler {
 	h2 := ColorHandler{
-		opts:   h.opts,
-		attrs:  slices.Clip(h.attrs),
-		groups: slices.Clip(h.groups),
-		mu:     h.mu,
-		w:      h.w,
-		scheme: h.scheme,
+		opts:       h.opts,
+		attrs:      slices.Clip(h.attrs),
+		groups:     slices.Clip(h.groups),
+		openGroups: h.openGroups,
+		mu:         h.mu,
+		w:          h.w,
+		start:      h.start,
+		term:       h.term,
+		html:       h.html,
+		scheme:     h.scheme,
 	}
 	return &h2
 }
 
-func appendAttr(buf []byte, prefix string, a slog.Attr, rep func(groups []string, a slog.Attr) slog.Attr, groups []string, pk, pv, pb Colorizer) []byte {
+func (h *ColorHandler) appendAttr(buf []byte, prefix string, a slog.Attr, groups []string) []byte {
+	rep := h.opts.ReplaceAttr
+	s := &h.scheme
+
 	a.Value = a.Value.Resolve()
 
 	if a.Value.Kind() == slog.KindGroup {
-		if prefix == "" {
-			prefix = a.Key
-		} else {
-			prefix += "." + a.Key
+		// inline if no key
+		if a.Key != "" {
+			if prefix == "" {
+				prefix = a.Key
+			} else {
+				prefix += "." + a.Key
+			}
+			groups = append(slices.Clip(groups), a.Key)
 		}
 		for _, child := range a.Value.Group() {
-			buf = appendAttr(buf, prefix, child, rep, groups, pk, pv, pb)
+			buf = h.appendAttr(buf, prefix, child, groups)
 		}
 	} else {
 		if rep != nil {
@@ -280,14 +277,19 @@ func appendAttr(buf []byte, prefix string, a slog.Attr, rep func(groups []string
 			a.Value = a.Value.Resolve()
 		}
 
+		pk, pv := s.AttrPrinters(prefix, a.Key, a.Value)
+		pb := s.BasePrinter()
+
 		buf = append(buf, ' ')
 
 		buf = pk.AppendFormat(buf)
+		start := len(buf)
 		if prefix != "" {
-			buf = append(buf, prefix...)
-			buf = append(buf, '.')
+			buf = h.appendKey(buf, prefix+"."+a.Key)
+		} else {
+			buf = h.appendKey(buf, a.Key)
 		}
-		buf = append(buf, a.Key...)
+		buf = h.escape(buf, start)
 		buf = pk.AppendUnformat(buf)
 
 		buf = pb.AppendFormat(buf)
@@ -295,7 +297,9 @@ func appendAttr(buf []byte, prefix string, a slog.Attr, rep func(groups []string
 		buf = pb.AppendUnformat(buf)
 
 		buf = pv.AppendFormat(buf)
-		buf = appendQuote(buf, a.Value.String())
+		start = len(buf)
+		buf = h.appendValue(buf, a.Value)
+		buf = h.escape(buf, start)
 		buf = pv.AppendUnformat(buf)
 	}
 
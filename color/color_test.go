@@ -2,17 +2,27 @@ package color_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"testing/slogtest"
+	"time"
 
 	fatihsan "github.com/fatih/color"
 	"github.com/shu-go/gotwant"
 	"github.com/shu-go/shandler/color"
+	"github.com/shu-go/shandler/leveled"
 	stesting "github.com/shu-go/shandler/testing"
 )
 
@@ -35,7 +45,7 @@ func (b logbackup) restore() {
 
 func TestColor(t *testing.T) {
 	cb := &bytes.Buffer{}
-	cl := slog.New(color.NewHandler(cb, nil, color.DefaultNilScheme()))
+	cl := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone}, color.DefaultNilScheme()))
 	//sb := &bytes.Buffer{}
 	//sl := slog.New(slog.NewTextHandler(sb, nil))
 
@@ -97,6 +107,7 @@ func TestColor(t *testing.T) {
 	t.Run("ReplaceAttr", func(t *testing.T) {
 		cb.Reset()
 		cl := slog.New(color.NewHandler(cb, &color.HandlerOptions{
+			TimeFormat: color.TimeNone,
 			ReplaceAttr: func(group []string, a slog.Attr) slog.Attr {
 				fmt.Fprintf(os.Stderr, "group: %+v\n", group)
 				if strings.HasPrefix(a.Key, "str") {
@@ -125,7 +136,7 @@ func TestColor(t *testing.T) {
 		log.SetFlags(log.LstdFlags | log.Lshortfile)
 
 		cb.Reset()
-		h := color.NewHandler(cb, &color.HandlerOptions{Compat: true}, color.DefaultNilScheme())
+		h := color.NewHandler(cb, &color.HandlerOptions{Compat: true, FollowLogFlags: true}, color.DefaultNilScheme())
 
 		err := slogtest.TestHandler(h, func() []map[string]any {
 			return stesting.ParseTextLogs(t, cb.Bytes(), true)
@@ -136,6 +147,978 @@ func TestColor(t *testing.T) {
 	})
 }
 
+func TestFormat(t *testing.T) {
+	defer backup().restore()
+	// ignored
+	log.SetFlags(log.Lmicroseconds | log.Lshortfile)
+
+	tm := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.FixedZone("JST", 9*60*60))
+	pc := func() uintptr {
+		var pcs [1]uintptr
+		runtime.Callers(2, pcs[:])
+		return pcs[0]
+	}()
+
+	test := func(t *testing.T, opts color.HandlerOptions, want string) {
+		t.Helper()
+
+		cb := &bytes.Buffer{}
+		h := color.NewHandler(cb, &opts, color.DefaultNilScheme())
+		h.Handle(context.Background(), slog.NewRecord(tm, slog.LevelInfo, "message", pc))
+		gotwant.Test(t, cb.String(), want, gotwant.Format("%q"))
+	}
+
+	t.Run("Time", func(t *testing.T) {
+		test(t, color.HandlerOptions{}, "2024/05/06 07:08:09 INFO message\n")
+		test(t, color.HandlerOptions{TimeFormat: time.RFC3339}, "2024-05-06T07:08:09+09:00 INFO message\n")
+		test(t, color.HandlerOptions{TimeFormat: time.Kitchen, TimeUTC: true}, "10:08PM INFO message\n")
+		test(t, color.HandlerOptions{TimeFormat: color.TimeUnixMilli}, fmt.Sprintf("%d INFO message\n", tm.UnixMilli()))
+		test(t, color.HandlerOptions{TimeFormat: color.TimeNone}, "INFO message\n")
+
+		cb := &bytes.Buffer{}
+		h := color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeRelative}, color.DefaultNilScheme())
+		slog.New(h).Info("message")
+		gotwant.TestExpr(t, cb.String(), strings.HasPrefix(cb.String(), "+") && strings.HasSuffix(cb.String(), "s INFO message\n"))
+	})
+
+	t.Run("Source", func(t *testing.T) {
+		_, file, _, _ := runtime.Caller(0)
+		line := sourceLine(pc)
+
+		test(t, color.HandlerOptions{AddSource: true, TimeFormat: color.TimeNone}, fmt.Sprintf("%s:%d: INFO message\n", file, line))
+		test(t, color.HandlerOptions{AddSource: true, TimeFormat: color.TimeNone, SourceFormat: color.SourceShort}, fmt.Sprintf("color_test.go:%d: INFO message\n", line))
+		test(t, color.HandlerOptions{AddSource: true, TimeFormat: color.TimeNone, SourceFormat: color.SourceRelative}, fmt.Sprintf("color/color_test.go:%d: INFO message\n", line))
+		test(t, color.HandlerOptions{AddSource: true, TimeFormat: color.TimeNone, SourceFormat: color.SourceFunc}, fmt.Sprintf("color_test.TestFormat:%d: INFO message\n", line))
+		test(t, color.HandlerOptions{TimeFormat: color.TimeNone, SourceFormat: color.SourceFunc}, "INFO message\n")
+
+		// files in other directories
+		relative := func(t *testing.T, fn any, want string) {
+			t.Helper()
+
+			pc := reflect.ValueOf(fn).Pointer()
+			cb := &bytes.Buffer{}
+			h := color.NewHandler(cb, &color.HandlerOptions{AddSource: true, TimeFormat: color.TimeNone, SourceFormat: color.SourceRelative}, color.DefaultNilScheme())
+			h.Handle(context.Background(), slog.NewRecord(tm, slog.LevelInfo, "message", pc))
+			gotwant.Test(t, cb.String(), fmt.Sprintf("%s:%d: INFO message\n", want, sourceLine(pc)))
+		}
+		relative(t, leveled.NewHandler, "leveled/leveled.go")
+		relative(t, slog.NewTextHandler, "log/slog/text_handler.go")
+	})
+
+	t.Run("FollowLogFlags", func(t *testing.T) {
+		test(t, color.HandlerOptions{FollowLogFlags: true}, fmt.Sprintf("07:08:09.123456 color_test.go:%d: INFO message\n", sourceLine(pc)))
+		test(t, color.HandlerOptions{FollowLogFlags: true, AddSource: true}, fmt.Sprintf("07:08:09.123456 %s: INFO message\n", sourceFile(pc)))
+	})
+}
+
+func sourceLine(pc uintptr) int {
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return f.Line
+}
+
+func sourceFile(pc uintptr) string {
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return fmt.Sprintf("%s:%d", f.File, f.Line)
+}
+
+func TestCompat(t *testing.T) {
+	tm := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	pc := func() uintptr {
+		var pcs [1]uintptr
+		runtime.Callers(2, pcs[:])
+		return pcs[0]
+	}()
+
+	type point struct{ X, Y int }
+
+	record := func(msg string) slog.Record {
+		r := slog.NewRecord(tm, slog.LevelWarn+2, msg, pc)
+		r.Add(
+			"str", "value",
+			"empty", "",
+			"space", "a b",
+			"quote", `say "hi"`,
+			"eq", "a=b",
+			"newline", "line1\nline2",
+			"ctrl", "a\x00b",
+			"backslash", `a\b`,
+			"unicode", "日本語",
+			"int", 1,
+			"float", 1.5,
+			"bool", true,
+			"dur", time.Second,
+			"time", tm,
+			"err", errors.New("an error"),
+			"bytes", []byte("a b"),
+			"plainbytes", []byte("ab"),
+			"namedbytes", json.RawMessage("ab"),
+			"struct", point{1, 2},
+			"key with space", 1,
+			slog.Group("grp", slog.String("k=v", "x")),
+		)
+		r.Add([]any{"odd"}...) // !BADKEY
+		return r
+	}
+
+	for _, msg := range []string{"message", "", "a message", "multi\nline"} {
+		cb := &bytes.Buffer{}
+		sb := &bytes.Buffer{}
+
+		ch := color.NewHandler(cb, &color.HandlerOptions{Compat: true, AddSource: true}, color.DefaultNilScheme())
+		sh := slog.NewTextHandler(sb, &slog.HandlerOptions{AddSource: true})
+
+		for _, h := range []slog.Handler{ch, sh} {
+			h.Handle(context.Background(), record(msg))
+			h = h.WithAttrs([]slog.Attr{slog.String("a", "b c")}).WithGroup("g1").WithAttrs([]slog.Attr{slog.Int("i", 1)}).WithGroup("g2")
+			h.Handle(context.Background(), record(msg))
+		}
+
+		gotwant.Test(t, cb.String(), sb.String())
+	}
+
+	t.Run("NotCompat", func(t *testing.T) {
+		// quoted only if the value has spaces, tabs or '"'
+		cb := &bytes.Buffer{}
+		l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone}, color.DefaultNilScheme()))
+		l.Info("message", "empty", "", "eq", "a=b", "space", "a b", "k=v", "x")
+		gotwant.Test(t, cb.String(), `INFO message empty= eq=a=b space="a b" k=v=x`+"\n")
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		rep := func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == "drop" {
+				return slog.Attr{}
+			}
+			if a.Key == "groups" {
+				a.Value = slog.StringValue(strings.Join(groups, "/"))
+			}
+			return a
+		}
+
+		for _, rep := range []func([]string, slog.Attr) slog.Attr{nil, rep} {
+			cb := &bytes.Buffer{}
+			sb := &bytes.Buffer{}
+
+			ch := color.NewHandler(cb, &color.HandlerOptions{Compat: true, ReplaceAttr: rep}, color.DefaultNilScheme())
+			sh := slog.NewTextHandler(sb, &slog.HandlerOptions{ReplaceAttr: rep})
+
+			for _, h := range []slog.Handler{ch, sh} {
+				h = h.WithGroup("G")
+
+				r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+				r.Add(
+					slog.Group("", slog.Int("inline", 1)),
+					slog.Group("empty"),
+					slog.Int("drop", 1),
+					slog.Any("valuer", groupValuer{}),
+					slog.Group("g1", slog.Int("groups", 1), slog.Group("g2", slog.Int("groups", 2))),
+					slog.Int("groups", 3),
+				)
+				h.Handle(context.Background(), r)
+
+				h.WithGroup("H").Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0))
+
+				h.WithAttrs([]slog.Attr{
+					slog.Group("", slog.Int("inline", 1)),
+					slog.Int("groups", 4),
+					slog.Group("empty"),
+				}).Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0))
+			}
+
+			gotwant.Test(t, cb.String(), sb.String())
+		}
+	})
+}
+
+type groupValuer struct{}
+
+func (groupValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("x", 1), slog.Any("inner", innerGroupValuer{}))
+}
+
+type innerGroupValuer struct{}
+
+func (innerGroupValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("y", 2))
+}
+
+func TestReplaceBuiltin(t *testing.T) {
+	const LevelFatal = slog.Level(12)
+
+	tm := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	pc := func() uintptr {
+		var pcs [1]uintptr
+		runtime.Callers(2, pcs[:])
+		return pcs[0]
+	}()
+
+	rep := func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) != 0 {
+			return a
+		}
+
+		switch a.Key {
+		case slog.TimeKey:
+			if a.Value.Time().Year() == 2000 {
+				return slog.Attr{}
+			}
+			a.Value = slog.TimeValue(a.Value.Time().Add(time.Hour))
+		case slog.LevelKey:
+			if a.Value.Any().(slog.Level) == LevelFatal {
+				a.Value = slog.StringValue("FATAL")
+			}
+		case slog.MessageKey:
+			a.Key = "message"
+		case slog.SourceKey:
+			src := a.Value.Any().(*slog.Source)
+			src.File = filepath.Base(src.File)
+		}
+		return a
+	}
+
+	records := []slog.Record{
+		slog.NewRecord(tm, LevelFatal, "message", pc),
+		slog.NewRecord(tm, slog.LevelInfo, "message", pc),
+		slog.NewRecord(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), slog.LevelInfo, "message", pc),
+	}
+
+	t.Run("Compat", func(t *testing.T) {
+		cb := &bytes.Buffer{}
+		sb := &bytes.Buffer{}
+
+		ch := color.NewHandler(cb, &color.HandlerOptions{Compat: true, AddSource: true, ReplaceAttr: rep}, color.DefaultNilScheme())
+		sh := slog.NewTextHandler(sb, &slog.HandlerOptions{AddSource: true, ReplaceAttr: rep})
+
+		for _, r := range records {
+			ch.Handle(context.Background(), r)
+			sh.Handle(context.Background(), r)
+		}
+
+		gotwant.Test(t, cb.String(), sb.String())
+	})
+
+	t.Run("Color", func(t *testing.T) {
+		cb := &bytes.Buffer{}
+		h := color.NewHandler(cb, &color.HandlerOptions{AddSource: true, TimeFormat: time.TimeOnly, ReplaceAttr: rep}, color.DefaultNilScheme())
+
+		for _, r := range records {
+			h.Handle(context.Background(), r)
+		}
+
+		line := sourceLine(pc)
+		gotwant.Test(t, cb.String(), fmt.Sprintf(`08:08:09 color_test.go:%[1]d: FATAL message
+08:08:09 color_test.go:%[1]d: INFO message
+color_test.go:%[1]d: INFO message
+`, line))
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		cb := &bytes.Buffer{}
+		h := color.NewHandler(cb, &color.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}, color.DefaultNilScheme())
+
+		h.Handle(context.Background(), records[0])
+		gotwant.Test(t, cb.String(), "message\n")
+	})
+}
+
+func TestSlogtest(t *testing.T) {
+	var buf *bytes.Buffer
+
+	newHandler := func(t *testing.T) slog.Handler {
+		buf = &bytes.Buffer{}
+		return color.NewHandler(buf, &color.HandlerOptions{Compat: true}, color.DefaultNilScheme())
+	}
+	result := func(t *testing.T) map[string]any {
+		ms := stesting.ParseTextLogs(t, buf.Bytes(), false)
+		if len(ms) != 1 {
+			t.Fatalf("want 1 record, got %d: %q", len(ms), buf.String())
+		}
+		return ms[0]
+	}
+
+	slogtest.Run(t, newHandler, result)
+}
+
+// marker is a Colorizer that encloses with <name> and </name>.
+type marker string
+
+func (m marker) AppendFormat(b []byte) []byte {
+	return append(b, "<"+string(m)+">"...)
+}
+
+func (m marker) AppendUnformat(b []byte) []byte {
+	return append(b, "</"+string(m)+">"...)
+}
+
+func TestValueColor(t *testing.T) {
+	scheme := color.DefaultNilScheme()
+	scheme.AttrValue = marker("v")
+	scheme.Kind = map[slog.Kind]color.Colorizer{
+		slog.KindInt64:    marker("num"),
+		slog.KindFloat64:  marker("num"),
+		slog.KindBool:     marker("bool"),
+		slog.KindDuration: marker("dur"),
+		slog.KindAny:      marker("any"),
+	}
+	scheme.Error = marker("err")
+	scheme.Nil = marker("nil")
+
+	cb := &bytes.Buffer{}
+	l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone}, scheme))
+
+	l.Info("message",
+		"s", "str",
+		"i", 1,
+		"f", 1.5,
+		"b", true,
+		"d", time.Second,
+		"e", errors.New("error"),
+		"n", nil,
+		"a", []int{1},
+	)
+	gotwant.Test(t, cb.String(), "INFO message s=<v>str</v> i=<num>1</num> f=<num>1.5</num> b=<bool>true</bool> d=<dur>1s</dur> e=<err>error</err> n=<nil><nil></nil> a=<any>[1]</any>\n")
+
+	t.Run("Fallback", func(t *testing.T) {
+		scheme.Error = nil
+		scheme.Nil = nil
+		delete(scheme.Kind, slog.KindAny)
+
+		cb.Reset()
+		l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone}, scheme))
+		l.Info("message", "e", errors.New("error"), "n", nil)
+		gotwant.Test(t, cb.String(), "INFO message e=<v>error</v> n=<v><nil></v>\n")
+	})
+}
+
+func TestKeyRules(t *testing.T) {
+	scheme := color.DefaultNilScheme()
+	scheme.Keys = []color.KeyRule{
+		{Pattern: "err", Key: marker("errk"), Value: marker("errv")},
+		{Pattern: "http.*", Value: marker("http")},
+		{Pattern: "user", Key: marker("user")},
+		{Regexp: regexp.MustCompile(`(^|\.)request_id$`), Value: marker("rid")},
+	}
+
+	cb := &bytes.Buffer{}
+	l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone}, scheme))
+
+	l.With("request_id", "r1").Info("message",
+		"err", "e1",
+		slog.Group("http", "method", "GET", slog.Group("req", "path", "/")),
+		"user", "u1",
+		"other", "o1",
+	)
+	gotwant.Test(t, cb.String(), "INFO message request_id=<rid>r1</rid> <errk>err</errk>=<errv>e1</errv> http.method=<http>GET</http> http.req.path=<http>/</http> <user>user</user>=u1 other=o1\n")
+
+	t.Run("Multiline", func(t *testing.T) {
+		cb.Reset()
+		l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone, Multiline: true}, scheme))
+		l.WithGroup("http").Info("message", "method", "GET", "err", "e1")
+		gotwant.Test(t, cb.String(), `INFO message
+  http:
+    method=<http>GET</http>
+    <errk>err</errk>=<errv>e1</errv>
+`)
+	})
+}
+
+func TestSchemeText(t *testing.T) {
+	s, err := color.ParseScheme("level.error=red,bold:key=cyan:msg=white", nil)
+	gotwant.TestError(t, err, nil)
+
+	text, err := s.MarshalText()
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, string(text), "msg=white:key=cyan:level.error=red,bold")
+
+	t.Run("Full", func(t *testing.T) {
+		s, err := color.ParseScheme(`
+# comment
+base=hiblack,faint
+level.warn+2=yellow : level.-8=38
+kind.int=magenta:kind.bool=none
+error=bgred:nil=
+key[http.*]=blue:value[http.*]=hiwhite:value[/^req/]=green
+`, nil)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, len(s.Keys), 2)
+		gotwant.Test(t, s.Keys[1].Regexp.String(), "^req")
+
+		text, err := s.MarshalText()
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, string(text), "base=hiblack,faint:error=bgred:level.debug-4=38:level.warn+2=yellow:kind.bool=none:kind.int=magenta:key[http.*]=blue:value[http.*]=hiwhite:value[/^req/]=green")
+	})
+
+	t.Run("Colon", func(t *testing.T) {
+		s, err := color.ParseScheme(`key[/^http\:/]=blue:value[a\:b]=red:label.12=F\:X:msg=white`, nil)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, len(s.Keys), 2)
+		gotwant.Test(t, s.Keys[0].Regexp.String(), "^http:")
+		gotwant.Test(t, s.Keys[1].Pattern, "a:b")
+		gotwant.Test(t, s.Labels[12].Text, "F:X")
+		gotwant.TestExpr(t, s.Message, s.Message != nil)
+
+		text, err := s.MarshalText()
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, string(text), `msg=white:label.error+4=F\:X:key[/^http\:/]=blue:value[a\:b]=red`)
+
+		s2, err := color.ParseScheme(string(text), nil)
+		gotwant.TestError(t, err, nil)
+		text2, _ := s2.MarshalText()
+		gotwant.Test(t, string(text2), string(text))
+
+		_, err = color.ParseScheme(`key[a\:b]=blu`, nil)
+		var serr *color.SchemeError
+		gotwant.TestExpr(t, err, errors.As(err, &serr))
+		gotwant.Test(t, serr.Entry, `key[a\:b]=blu`)
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, base := range []*color.Scheme{color.DefaultLightScheme(), color.DefaultDarkScheme()} {
+			text, err := base.MarshalText()
+			gotwant.TestError(t, err, nil)
+
+			s, err := color.ParseScheme(string(text), nil)
+			gotwant.TestError(t, err, nil)
+
+			text2, err := s.MarshalText()
+			gotwant.TestError(t, err, nil)
+			gotwant.Test(t, string(text2), string(text))
+		}
+	})
+
+	t.Run("Overlay", func(t *testing.T) {
+		base := color.DefaultDarkScheme()
+		s, err := color.ParseScheme("level.error=blue:time=", base)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, s.Base, base.Base)
+		gotwant.Test(t, s.Level[slog.LevelWarn], base.Level[slog.LevelWarn])
+		gotwant.Test(t, s.Level[slog.LevelError].(*color.Color).Params, color.NewColor(34).Params)
+		gotwant.TestExpr(t, base.Level[slog.LevelError], base.Level[slog.LevelError] != s.Level[slog.LevelError])
+	})
+
+	t.Run("Env", func(t *testing.T) {
+		t.Setenv(color.EnvScheme, "msg=red")
+		s, err := color.SchemeFromEnv(nil)
+		gotwant.TestError(t, err, nil)
+		text, _ := s.MarshalText()
+		gotwant.Test(t, string(text), "msg=red")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		for _, c := range []struct {
+			text   string
+			offset int
+			entry  string
+		}{
+			{"key=cyan:msg=whte", 9, "msg=whte"},
+			{"key=cyan\n  level.eror=red", 11, "level.eror=red"},
+			{"key=cyan: hoge=red", 10, "hoge=red"},
+			{"msg", 0, "msg"},
+			{"value[/(/]=red", 0, "value[/(/]=red"},
+		} {
+			_, err := color.ParseScheme(c.text, nil)
+			var serr *color.SchemeError
+			gotwant.TestExpr(t, err, errors.As(err, &serr))
+			gotwant.Test(t, serr.Offset, c.offset)
+			gotwant.Test(t, serr.Entry, c.entry)
+		}
+
+		_, err := color.ParseScheme("msg=whte", nil)
+		gotwant.Test(t, err.Error(), `color: scheme: offset 0: "msg=whte": unknown attribute "whte"`)
+
+		_, err = color.Scheme{Message: marker("m")}.MarshalText()
+		gotwant.TestExpr(t, err, err != nil)
+	})
+}
+
+func TestExtColor(t *testing.T) {
+	f := false
+	c := color.NewExtColor(color.RGB(255, 135, 0), color.Index256(240), fatihsan.Bold)
+	c.NoColor = &f
+
+	test := func(t *testing.T, depth color.Depth, want string) {
+		t.Helper()
+		c.Depth = depth
+		gotwant.Test(t, string(c.AppendFormat(nil)), want, gotwant.Format("%q"))
+		gotwant.Test(t, string(c.AppendUnformat(nil)), "\x1b[22;39;49m", gotwant.Format("%q"))
+	}
+	test(t, color.DepthTrueColor, "\x1b[1;38;2;255;135;0;48;5;240m")
+	test(t, color.Depth256, "\x1b[1;38;5;208;48;5;240m")
+	test(t, color.Depth16, "\x1b[1;33;100m")
+
+	t.Run("Gray", func(t *testing.T) {
+		c := color.NewExtColor(color.RGB(128, 128, 128), color.ColorSpec{})
+		c.NoColor = &f
+		c.Depth = color.Depth256
+		gotwant.Test(t, string(c.AppendFormat(nil)), "\x1b[38;5;244m", gotwant.Format("%q"))
+	})
+
+	t.Run("Hex", func(t *testing.T) {
+		spec, err := color.Hex("#f80")
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, spec, color.RGB(0xff, 0x88, 0x00))
+		gotwant.Test(t, spec.String(), "#ff8800")
+
+		_, err = color.Hex("#ff880")
+		gotwant.TestExpr(t, err, err != nil)
+	})
+
+	t.Run("Depth", func(t *testing.T) {
+		t.Setenv("COLORTERM", "truecolor")
+		gotwant.Test(t, color.DetectDepth(), color.DepthTrueColor)
+		t.Setenv("COLORTERM", "")
+		t.Setenv("TERM", "xterm-256color")
+		gotwant.Test(t, color.DetectDepth(), color.Depth256)
+		t.Setenv("TERM", "xterm")
+		gotwant.Test(t, color.DetectDepth(), color.Depth16)
+	})
+
+	t.Run("SchemeText", func(t *testing.T) {
+		s, err := color.ParseScheme("msg=bold,#ff8700,bg@240:key=@33", nil)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, s.Message.(*color.ExtColor).Fg, color.RGB(255, 135, 0))
+		gotwant.Test(t, s.Message.(*color.ExtColor).Bg, color.Index256(240))
+
+		text, err := s.MarshalText()
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, string(text), "msg=bold,#ff8700,bg@240:key=@33")
+
+		_, err = color.ParseScheme("msg=@256", nil)
+		gotwant.TestExpr(t, err, err != nil)
+	})
+}
+
+func TestColorMode(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+
+	scheme := color.DefaultNilScheme()
+	scheme.Message = color.NewColor(fatihsan.FgRed)
+	scheme.AttrKey = marker("key")
+
+	test := func(t *testing.T, mode color.ColorMode, want string) {
+		t.Helper()
+
+		cb := &bytes.Buffer{}
+		l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone, ColorMode: mode}, scheme))
+		l.Info("message", "a", 1)
+		gotwant.Test(t, cb.String(), want, gotwant.Format("%q"))
+	}
+
+	colored := "INFO \x1b[31mmessage\x1b[0m <key>a</key>=1\n"
+	plain := "INFO message <key>a</key>=1\n"
+
+	test(t, color.ColorAlways, colored)
+	test(t, color.ColorNever, plain)
+	// not a terminal
+	test(t, color.ColorAuto, plain)
+
+	t.Setenv("FORCE_COLOR", "1")
+	test(t, color.ColorAuto, colored)
+	test(t, color.ColorNever, plain)
+	t.Setenv("FORCE_COLOR", "0")
+	test(t, color.ColorAuto, plain)
+
+	t.Setenv("CLICOLOR_FORCE", "1")
+	test(t, color.ColorAuto, colored)
+
+	t.Setenv("NO_COLOR", "1")
+	test(t, color.ColorAuto, plain)
+	test(t, color.ColorAlways, colored)
+
+	// not modified
+	gotwant.TestExpr(t, scheme.Message.(*color.Color).NoColor, scheme.Message.(*color.Color).NoColor == nil)
+}
+
+func TestHTML(t *testing.T) {
+	scheme := color.DefaultNilScheme()
+	scheme.Message = color.NewColor(fatihsan.FgRed, fatihsan.Bold)
+	scheme.AttrKey = color.NewColor(fatihsan.FgCyan)
+	scheme.Level = map[slog.Level]color.Colorizer{
+		slog.LevelError: color.NewExtColor(color.RGB(255, 0, 0), color.ColorSpec{}),
+	}
+	scheme.Keys = []color.KeyRule{{Pattern: "m", Value: marker("m")}}
+
+	test := func(t *testing.T, opts color.HandlerOptions, want string) {
+		t.Helper()
+
+		cb := &bytes.Buffer{}
+		opts.TimeFormat = color.TimeNone
+		l := slog.New(color.NewHTMLHandler(cb, &opts, scheme))
+		l.Error("<b>&", "k<", `v"`, "m", 1)
+		gotwant.Test(t, cb.String(), want)
+	}
+
+	test(t, color.HandlerOptions{},
+		`<span class="lvl-error">ERROR</span> <span class="msg">&lt;b&gt;&amp;</span> <span class="key">k&lt;</span>=&#34;v&#34;&#34; <span class="key">m</span>=<m>1</m>`+"\n")
+	test(t, color.HandlerOptions{InlineStyle: true},
+		`<span style="color:#ff0000">ERROR</span> <span style="font-weight:bold;color:#cd0000">&lt;b&gt;&amp;</span> <span style="color:#00cdcd">k&lt;</span>=&#34;v&#34;&#34; <span style="color:#00cdcd">m</span>=<m>1</m>`+"\n")
+	test(t, color.HandlerOptions{ColorMode: color.ColorNever},
+		`ERROR &lt;b&gt;&amp; k&lt;=&#34;v&#34;&#34; m=<m>1</m>`+"\n")
+
+	t.Run("Multiline", func(t *testing.T) {
+		test(t, color.HandlerOptions{Multiline: true, ColorMode: color.ColorNever},
+			"ERROR &lt;b&gt;&amp;\n  k&lt;=&#34;v&#34;&#34;\n  m=<m>1</m>\n")
+	})
+
+	t.Run("StyleSheet", func(t *testing.T) {
+		gotwant.Test(t, color.StyleSheet(scheme), `.msg { font-weight:bold; color:#cd0000; }
+.key { color:#00cdcd; }
+.lvl-error { color:#ff0000; }
+`)
+	})
+
+	// not modified
+	gotwant.Test(t, scheme.Message.(*color.Color).Params, []fatihsan.Attribute{fatihsan.FgRed, fatihsan.Bold})
+}
+
+func TestANSI(t *testing.T) {
+	key := color.NewExtColor(color.RGB(1, 2, 3), color.ColorSpec{})
+	key.Depth = color.DepthTrueColor
+
+	scheme := color.DefaultNilScheme()
+	scheme.Message = color.NewColor(fatihsan.FgRed, fatihsan.Bold)
+	scheme.AttrKey = key
+
+	cb := &bytes.Buffer{}
+	l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone, ColorMode: color.ColorAlways}, scheme))
+	l.Info("<message>", "k", "v")
+	colored := cb.Bytes()
+
+	t.Run("Strip", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		w := color.Strip(out)
+		// split sequences
+		for i := range colored {
+			n, err := w.Write(colored[i : i+1])
+			gotwant.TestError(t, err, nil)
+			gotwant.Test(t, n, 1)
+		}
+		gotwant.Test(t, out.String(), "INFO <message> k=v\n")
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		w := color.ANSIToHTML(out)
+		w.Write(colored[:7])
+		w.Write(colored[7:])
+		gotwant.TestError(t, w.Close(), nil)
+		gotwant.Test(t, out.String(), `INFO <span style="font-weight:bold;color:#cd0000">&lt;message&gt;</span> <span style="color:#010203">k</span>=v`+"\n")
+
+		out.Reset()
+		w = color.ANSIToHTML(out)
+		w.Write([]byte("\x1b[4;38;5;208ma\x1b[1mb\x1b[24mc\x1b[2Kd"))
+		gotwant.TestError(t, w.Close(), nil)
+		gotwant.Test(t, out.String(), `<span style="color:#ff8700;text-decoration:underline">a</span>`+
+			`<span style="font-weight:bold;color:#ff8700;text-decoration:underline">b</span>`+
+			`<span style="font-weight:bold;color:#ff8700">cd</span>`)
+
+		// back-to-back sequences
+		out.Reset()
+		w = color.ANSIToHTML(out)
+		w.Write([]byte("\x1b\x1b[31ma\x1b[0m\x1b[1m\x1b[32mb\x1b[3\x1b[0mc"))
+		gotwant.TestError(t, w.Close(), nil)
+		gotwant.Test(t, out.String(), `<span style="color:#cd0000">a</span>`+
+			`<span style="font-weight:bold"></span><span style="font-weight:bold;color:#00cd00">b</span>c`)
+	})
+
+	t.Run("DefaultDarkScheme", func(t *testing.T) {
+		cb := &bytes.Buffer{}
+		l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone, ColorMode: color.ColorAlways}, color.DefaultDarkScheme()))
+		l.Warn("<message>", "k", "v", "err", errors.New("an error"))
+
+		out := &bytes.Buffer{}
+		w := color.ANSIToHTML(out)
+		w.Write(cb.Bytes())
+		gotwant.TestError(t, w.Close(), nil)
+
+		html := out.String()
+		gotwant.TestExpr(t, html, !strings.Contains(html, "\x1b"))
+		gotwant.Test(t, strings.Count(html, "<span "), strings.Count(html, "</span>"))
+		text := regexp.MustCompile(`<[^>]*>`).ReplaceAllString(html, "")
+		gotwant.Test(t, text, "WARN &lt;message&gt; k=v err=&#34;an error&#34;\n")
+	})
+}
+
+func TestLayout(t *testing.T) {
+	test := func(t *testing.T, opts color.HandlerOptions, level slog.Level, msg string, args []any, want string) {
+		t.Helper()
+
+		cb := &bytes.Buffer{}
+		opts.TimeFormat = color.TimeNone
+		opts.Level = slog.LevelDebug - 4
+		// widths ignore escape sequences
+		opts.ColorMode = color.ColorAlways
+		l := slog.New(color.NewHandler(color.Strip(cb), &opts, color.DefaultDarkScheme()))
+		l.Log(context.Background(), level, msg, args...)
+		gotwant.Test(t, cb.String(), want, gotwant.Format("%q"))
+	}
+
+	t.Run("Level", func(t *testing.T) {
+		padded := color.HandlerOptions{LevelFormat: color.LevelPadded}
+		test(t, padded, slog.LevelInfo, "message", nil, "INFO  message\n")
+		test(t, padded, slog.LevelError, "message", nil, "ERROR message\n")
+		test(t, padded, slog.LevelWarn+2, "message", nil, "WARN+2 message\n")
+
+		short := color.HandlerOptions{LevelFormat: color.LevelShort}
+		test(t, short, slog.LevelInfo, "message", nil, "INF message\n")
+		test(t, short, slog.LevelWarn+2, "message", nil, "WRN+2 message\n")
+		test(t, short, slog.LevelDebug-4, "message", nil, "DBG-4 message\n")
+		test(t, short, slog.LevelError+4, "message", nil, "ERR+4 message\n")
+
+		test(t, color.HandlerOptions{LevelFormat: color.LevelShort, Compat: true}, slog.LevelInfo, "message", nil, "level=INFO msg=message\n")
+	})
+
+	t.Run("MessageWidth", func(t *testing.T) {
+		opts := color.HandlerOptions{MessageWidth: 10}
+		test(t, opts, slog.LevelInfo, "msg", []any{"a", 1}, "INFO msg        a=1\n")
+		test(t, opts, slog.LevelInfo, "日本語", []any{"a", 1}, "INFO 日本語     a=1\n")
+		test(t, opts, slog.LevelInfo, "long message", []any{"a", 1}, "INFO long message a=1\n")
+		// no attrs
+		test(t, opts, slog.LevelInfo, "msg", nil, "INFO msg\n")
+	})
+
+	t.Run("Width", func(t *testing.T) {
+		args := []any{"a", 1, "b", 22, "c", 333}
+		test(t, color.HandlerOptions{Width: 30}, slog.LevelInfo, "message", args, "INFO message a=1 b=22 c=333\n")
+		test(t, color.HandlerOptions{Width: 20}, slog.LevelInfo, "message", args, "INFO message a=1 …\n")
+		test(t, color.HandlerOptions{Width: 20, Wrap: true}, slog.LevelInfo, "message", args, "INFO message a=1\n  b=22 c=333\n")
+		test(t, color.HandlerOptions{Width: 20, Wrap: true}, slog.LevelInfo, "message", []any{"a", "x y z w", "b", 2}, "INFO message\n  a=\"x y z w\" b=2\n")
+
+		// message wider than Width, no attrs
+		long := "a very long message that exceeds the width"
+		test(t, color.HandlerOptions{Width: 20}, slog.LevelInfo, long, nil, "INFO "+long+"\n")
+		test(t, color.HandlerOptions{Width: 20, Wrap: true}, slog.LevelInfo, long, nil, "INFO "+long+"\n")
+		// message wider than Width, with attrs
+		test(t, color.HandlerOptions{Width: 20}, slog.LevelInfo, long, []any{"a", 1}, "INFO "+long+" …\n")
+		test(t, color.HandlerOptions{Width: 20, Wrap: true}, slog.LevelInfo, long, []any{"a", 1}, "INFO "+long+"\n  a=1\n")
+
+		t.Setenv("COLUMNS", "20")
+		test(t, color.HandlerOptions{Width: color.WidthTerminal}, slog.LevelInfo, "message", args, "INFO message a=1 …\n")
+	})
+}
+
+func TestLevelFallback(t *testing.T) {
+	scheme := color.DefaultNilScheme()
+	scheme.Level = map[slog.Level]color.Colorizer{
+		slog.LevelInfo:  marker("info"),
+		slog.LevelWarn:  nil,
+		slog.LevelError: marker("error"),
+	}
+
+	cb := &bytes.Buffer{}
+	l := slog.New(color.NewHandler(cb, &color.HandlerOptions{TimeFormat: color.TimeNone, Level: slog.LevelDebug}, scheme))
+
+	for _, c := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug, "DEBUG"},
+		{slog.LevelInfo, "<info>INFO</info>"},
+		{slog.LevelInfo + 1, "<info>INFO+1</info>"},
+		{slog.LevelWarn, "<info>WARN</info>"},
+		{slog.LevelError, "<error>ERROR</error>"},
+		{slog.LevelError + 1, "<error>ERROR+1</error>"},
+		{slog.LevelError + 8, "<error>ERROR+8</error>"},
+	} {
+		cb.Reset()
+		l.Log(context.Background(), c.level, "message")
+		gotwant.Test(t, cb.String(), c.want+" message\n")
+	}
+
+	gotwant.Test(t, scheme.LevelPrinter(slog.LevelError+1), color.Colorizer(marker("error")))
+
+	t.Run("CustomLevel", func(t *testing.T) {
+		// a custom level colors built-in levels above it that are not in Level
+		scheme := color.DefaultNilScheme()
+		scheme.Level = map[slog.Level]color.Colorizer{
+			2: marker("notice"),
+		}
+
+		gotwant.Test(t, scheme.LevelPrinter(slog.LevelInfo), scheme.BasePrinter())
+		gotwant.Test(t, scheme.LevelPrinter(2), color.Colorizer(marker("notice")))
+		gotwant.Test(t, scheme.LevelPrinter(slog.LevelWarn), color.Colorizer(marker("notice")))
+		gotwant.Test(t, scheme.LevelPrinter(slog.LevelError), color.Colorizer(marker("notice")))
+	})
+}
+
+func TestLevelLabels(t *testing.T) {
+	scheme := color.DefaultNilScheme()
+	scheme.Labels = map[slog.Level]color.LevelLabel{
+		-8:              {Text: "TRACE"},
+		2:               {Text: "NOTICE"},
+		12:              {Text: "FATAL", Icon: "💀"},
+		slog.LevelError: {Icon: "❌"},
+	}
+	scheme.Level = map[slog.Level]color.Colorizer{
+		2:  marker("notice"),
+		12: marker("fatal"),
+	}
+
+	test := func(t *testing.T, opts color.HandlerOptions, level slog.Level, want string) {
+		t.Helper()
+
+		cb := &bytes.Buffer{}
+		opts.TimeFormat = color.TimeNone
+		opts.Level = slog.Level(-100)
+		l := slog.New(color.NewHandler(cb, &opts, scheme))
+		l.Log(context.Background(), level, "message")
+		gotwant.Test(t, cb.String(), want+" message\n")
+	}
+
+	opts := color.HandlerOptions{}
+	test(t, opts, -8, "TRACE")
+	test(t, opts, -10, "TRACE-2")
+	test(t, opts, slog.LevelDebug, "DEBUG")
+	test(t, opts, slog.LevelInfo, "INFO")
+	test(t, opts, 2, "<notice>NOTICE</notice>")
+	test(t, opts, 3, "<notice>NOTICE+1</notice>")
+	// colored by NOTICE, the nearest lower level in Level (see TestLevelFallback)
+	test(t, opts, slog.LevelWarn, "<notice>WARN</notice>")
+	test(t, opts, slog.LevelWarn+1, "<notice>WARN+1</notice>")
+	test(t, opts, slog.LevelError, "<notice>❌ ERROR</notice>")
+	test(t, opts, slog.LevelError+1, "<notice>❌ ERROR+1</notice>")
+	test(t, opts, 12, "<fatal>💀 FATAL</fatal>")
+	test(t, opts, 14, "<fatal>💀 FATAL+2</fatal>")
+
+	opts = color.HandlerOptions{LevelFormat: color.LevelShort}
+	test(t, opts, slog.LevelInfo, "INF")
+	test(t, opts, slog.LevelError, "<notice>❌ ERR</notice>")
+	test(t, opts, 3, "<notice>NOTICE+1</notice>")
+
+	opts = color.HandlerOptions{LevelFormat: color.LevelPadded}
+	test(t, opts, slog.LevelInfo, "INFO ")
+	test(t, opts, -8, "TRACE")
+
+	t.Run("Compat", func(t *testing.T) {
+		cb := &bytes.Buffer{}
+		l := slog.New(color.NewHandler(cb, &color.HandlerOptions{Compat: true, TimeFormat: color.TimeNone}, scheme))
+		l.Log(context.Background(), 12, "message")
+		gotwant.Test(t, cb.String(), "level=<fatal>ERROR+4</fatal> msg=message\n")
+	})
+
+	t.Run("SchemeText", func(t *testing.T) {
+		s, err := color.ParseScheme("label.12=FATAL:icon.error+4=💀:label.-8=TRACE", nil)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, s.Labels[12], color.LevelLabel{Text: "FATAL", Icon: "💀"})
+
+		text, err := s.MarshalText()
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, string(text), "label.debug-4=TRACE:label.error+4=FATAL:icon.error+4=💀")
+
+		s, err = color.ParseScheme("label.12=", s)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, s.Labels[12], color.LevelLabel{Icon: "💀"})
+	})
+}
+
+func TestRace(t *testing.T) {
+	defer backup().restore()
+
+	// the time formats of log.Flags() are used by fresh handlers concurrently while log.SetFlags
+	flags := []int{log.LstdFlags | log.Lmicroseconds, log.Ltime, log.Ldate}
+	line := regexp.MustCompile(`^(\d{4}/\d\d/\d\d \d\d:\d\d:\d\d\.\d{6}|\d\d:\d\d:\d\d|\d{4}/\d\d/\d\d) INFO message$`)
+
+	log.SetFlags(flags[0])
+	done := make(chan struct{})
+	flipped := make(chan struct{})
+	go func() {
+		defer close(flipped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			log.SetFlags(flags[i%len(flags)])
+		}
+	}()
+
+	for round := 0; round < 100; round++ {
+		cb := &bytes.Buffer{}
+		h := color.NewHandler(cb, &color.HandlerOptions{FollowLogFlags: true}, color.DefaultNilScheme())
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "message", 0))
+			}()
+		}
+		wg.Wait()
+
+		for _, l := range strings.Split(strings.TrimSuffix(cb.String(), "\n"), "\n") {
+			gotwant.TestExpr(t, l, line.MatchString(l))
+		}
+	}
+
+	close(done)
+	<-flipped
+}
+
+func TestMultiline(t *testing.T) {
+	defer backup().restore()
+	log.SetFlags(0)
+
+	cb := &bytes.Buffer{}
+	cl := slog.New(color.NewHandler(cb, &color.HandlerOptions{Multiline: true, TimeFormat: color.TimeNone}, color.DefaultNilScheme()))
+
+	t.Run("Groups", func(t *testing.T) {
+		cb.Reset()
+		cl.With(slog.String("s0", "value1")).WithGroup("grp1").With(slog.Int("i1", 1)).WithGroup("grp2").Info(
+			"message",
+			slog.String("str1", "value 1"),
+			slog.Group("grp3", slog.Int("int3", 3)),
+			slog.Group("empty"),
+		)
+		gotwant.Test(t, cb.String(), `INFO message
+  s0=value1
+  grp1:
+    i1=1
+    grp2:
+      str1="value 1"
+      grp3:
+        int3=3
+`)
+	})
+
+	t.Run("EmptyGroup", func(t *testing.T) {
+		cb.Reset()
+		cl.WithGroup("grp1").WithGroup("grp2").Info("message")
+		gotwant.Test(t, cb.String(), "INFO message\n")
+	})
+
+	t.Run("Any", func(t *testing.T) {
+		cb.Reset()
+		cl.WithGroup("grp1").Info(
+			"message",
+			slog.Any("map", map[string]int{"a": 1, "b": 2}),
+			slog.Any("slice", []string{"x"}),
+			slog.Any("err", fmt.Errorf("error 1")),
+		)
+		gotwant.Test(t, cb.String(), `INFO message
+  grp1:
+    map={
+      "a": 1,
+      "b": 2
+    }
+    slice=[
+      "x"
+    ]
+    err="error 1"
+`)
+	})
+}
+
 func TestColorShowcase(t *testing.T) {
 	defer backup().restore()
 
@@ -147,7 +1130,8 @@ func TestColorShowcase(t *testing.T) {
 
 	h := color.NewHandler(os.Stderr, &color.HandlerOptions{
 		//AddSource: true,
-		Level: slog.LevelDebug,
+		Level:          slog.LevelDebug,
+		FollowLogFlags: true,
 	}, scheme)
 	l := slog.New(h)
 
@@ -0,0 +1,126 @@
+package color
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ColorMode decides whether ColorHandler emits escape sequences of Color and ExtColor.
+//
+// Other Colorizers are used as they are.
+type ColorMode int
+
+const (
+	// ColorAuto colors if the writer is a terminal.
+	//
+	// NO_COLOR disables colors, and FORCE_COLOR or CLICOLOR_FORCE enables them.
+	// TERM=dumb disables colors.
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+// colorEnabled decides by w and environment variables.
+func colorEnabled(w io.Writer, mode ColorMode) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if noColorIsSet() {
+		return false
+	}
+	if envIsSet("FORCE_COLOR") || envIsSet("CLICOLOR_FORCE") {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// set, and neither "0" nor "false"
+func envIsSet(name string) bool {
+	v := os.Getenv(name)
+	return v != "" && v != "0" && v != "false"
+}
+
+// applyColorMode returns s whose Colors and ExtColors are forced on or off.
+func (s Scheme) applyColorMode(enabled, always bool) Scheme {
+	return s.mapColorizers(func(c Colorizer) Colorizer {
+		switch c := c.(type) {
+		case *Color:
+			if c.NoColor != nil && enabled && !always {
+				// set by the user or NO_COLOR
+				return c
+			}
+			c2 := *c
+			c2.NoColor = boolPtr(!enabled)
+			return &c2
+
+		case *ExtColor:
+			if c.NoColor != nil && enabled && !always {
+				return c
+			}
+			c2 := *c
+			c2.NoColor = boolPtr(!enabled)
+			return &c2
+
+		default:
+			return c
+		}
+	})
+}
+
+// mapColorizers returns a copy of s whose Colorizers (not nil) are mapped by f.
+func (s Scheme) mapColorizers(f func(Colorizer) Colorizer) Scheme {
+	m := func(c Colorizer) Colorizer {
+		if c == nil {
+			return nil
+		}
+		return f(c)
+	}
+
+	s.Base = m(s.Base)
+	s.Time = m(s.Time)
+	s.Source = m(s.Source)
+	s.Message = m(s.Message)
+	s.AttrKey = m(s.AttrKey)
+	s.AttrValue = m(s.AttrValue)
+	s.Error = m(s.Error)
+	s.Nil = m(s.Nil)
+
+	if s.Level != nil {
+		level := make(map[slog.Level]Colorizer, len(s.Level))
+		for l, c := range s.Level {
+			level[l] = m(c)
+		}
+		s.Level = level
+	}
+	if s.Kind != nil {
+		kind := make(map[slog.Kind]Colorizer, len(s.Kind))
+		for k, c := range s.Kind {
+			kind[k] = m(c)
+		}
+		s.Kind = kind
+	}
+
+	s.Keys = slices.Clone(s.Keys)
+	for i := range s.Keys {
+		s.Keys[i].Key = m(s.Keys[i].Key)
+		s.Keys[i].Value = m(s.Keys[i].Value)
+	}
+
+	return s
+}
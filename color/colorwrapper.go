@@ -114,6 +114,10 @@ func (c *Color) isNoColorSet() bool {
 	return NoColor
 }
 
+// NoColor disables Colors whose NoColor is nil.
+//
+// It is decided by os.Stdout.
+// ColorHandler decides by its writer and HandlerOptions.ColorMode instead.
 var (
 	NoColor = noColorIsSet() || os.Getenv("TERM") == "dumb" ||
 		(!isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()))
@@ -0,0 +1,305 @@
+package color
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Depth is a number of colors of a terminal.
+type Depth int
+
+const (
+	// DepthAuto means DefaultDepth.
+	DepthAuto Depth = iota
+	Depth16
+	Depth256
+	DepthTrueColor
+)
+
+// DefaultDepth is the Depth of ExtColor whose Depth is DepthAuto.
+var DefaultDepth = DetectDepth()
+
+// DetectDepth guesses the Depth of the terminal from COLORTERM and TERM.
+func DetectDepth() Depth {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return DepthTrueColor
+	}
+
+	term := strings.ToLower(os.Getenv("TERM"))
+	switch {
+	case strings.Contains(term, "truecolor"), strings.Contains(term, "direct"):
+		return DepthTrueColor
+	case strings.Contains(term, "256color"):
+		return Depth256
+	}
+
+	return Depth16
+}
+
+// ColorSpec is a foreground or background color of ExtColor.
+//
+// The zero value is no color.
+type ColorSpec struct {
+	kind    specKind
+	index   uint8
+	r, g, b uint8
+}
+
+type specKind uint8
+
+const (
+	specNone specKind = iota
+	specIndex
+	specRGB
+)
+
+// Index256 is a color of the 256-color palette.
+func Index256(n uint8) ColorSpec {
+	return ColorSpec{kind: specIndex, index: n}
+}
+
+// RGB is a 24-bit color.
+func RGB(r, g, b uint8) ColorSpec {
+	return ColorSpec{kind: specRGB, r: r, g: g, b: b}
+}
+
+// Hex parses "#rrggbb" or "#rgb".
+func Hex(s string) (ColorSpec, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return ColorSpec{}, fmt.Errorf("invalid hex color %q", s)
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return ColorSpec{}, fmt.Errorf("invalid hex color %q", s)
+	}
+	return RGB(uint8(n>>16), uint8(n>>8), uint8(n)), nil
+}
+
+// IsZero reports whether c is no color.
+func (c ColorSpec) IsZero() bool {
+	return c.kind == specNone
+}
+
+// String returns "#rrggbb", "@n" or "".
+func (c ColorSpec) String() string {
+	switch c.kind {
+	case specIndex:
+		return "@" + strconv.Itoa(int(c.index))
+	case specRGB:
+		return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+	default:
+		return ""
+	}
+}
+
+// ExtColor is a Colorizer of 256 colors and 24-bit colors.
+//
+// Colors are downsampled to Depth.
+type ExtColor struct {
+	Fg, Bg ColorSpec
+	// such as color.Bold
+	Attrs []color.Attribute
+
+	Depth   Depth
+	NoColor *bool
+}
+
+func NewExtColor(fg, bg ColorSpec, attrs ...color.Attribute) *ExtColor {
+	c := &ExtColor{
+		Fg:    fg,
+		Bg:    bg,
+		Attrs: attrs,
+	}
+	if noColorIsSet() {
+		c.NoColor = boolPtr(true)
+	}
+	return c
+}
+
+func (c *ExtColor) AppendFormat(b []byte) []byte {
+	if c.isNoColorSet() {
+		return b
+	}
+
+	depth := c.Depth
+	if depth == DepthAuto {
+		depth = DefaultDepth
+	}
+
+	b = append(b, '\x1b', '[')
+	n := 0
+	for _, a := range c.Attrs {
+		b = appendParam(b, &n, int(a))
+	}
+	if !c.Fg.IsZero() {
+		b = c.Fg.appendSequence(b, &n, depth, false)
+	}
+	if !c.Bg.IsZero() {
+		b = c.Bg.appendSequence(b, &n, depth, true)
+	}
+	b = append(b, 'm')
+	return b
+}
+
+func (c *ExtColor) AppendUnformat(b []byte) []byte {
+	if c.isNoColorSet() {
+		return b
+	}
+
+	b = append(b, '\x1b', '[')
+	n := 0
+	for _, a := range c.Attrs {
+		if ra, found := mapResetAttributes[a]; found {
+			b = appendParam(b, &n, int(ra))
+		} else if a >= color.FgBlack && a <= color.FgWhite || a >= color.FgHiBlack && a <= color.FgHiWhite {
+			b = appendParam(b, &n, 39)
+		} else if a >= color.BgBlack && a <= color.BgWhite || a >= color.BgHiBlack && a <= color.BgHiWhite {
+			b = appendParam(b, &n, 49)
+		} else {
+			b = appendParam(b, &n, int(color.Reset))
+		}
+	}
+	if !c.Fg.IsZero() {
+		b = appendParam(b, &n, 39)
+	}
+	if !c.Bg.IsZero() {
+		b = appendParam(b, &n, 49)
+	}
+	b = append(b, 'm')
+	return b
+}
+
+func (c *ExtColor) isNoColorSet() bool {
+	if c.NoColor != nil {
+		return *c.NoColor
+	}
+	return NoColor
+}
+
+func appendParam(b []byte, n *int, p int) []byte {
+	if *n > 0 {
+		b = append(b, ';')
+	}
+	*n++
+	return strconv.AppendInt(b, int64(p), 10)
+}
+
+func (c ColorSpec) appendSequence(b []byte, n *int, depth Depth, bg bool) []byte {
+	base := 30
+	if bg {
+		base = 40
+	}
+
+	switch {
+	case c.kind == specRGB && depth == DepthTrueColor:
+		b = appendParam(b, n, base+8)
+		b = appendParam(b, n, 2)
+		b = appendParam(b, n, int(c.r))
+		b = appendParam(b, n, int(c.g))
+		return appendParam(b, n, int(c.b))
+
+	case depth == Depth256 || depth == DepthTrueColor:
+		index := c.index
+		if c.kind == specRGB {
+			index = rgbTo256(c.r, c.g, c.b)
+		}
+		b = appendParam(b, n, base+8)
+		b = appendParam(b, n, 5)
+		return appendParam(b, n, int(index))
+
+	default:
+		index := c.index
+		if c.kind == specRGB {
+			index = rgbTo16(c.r, c.g, c.b)
+		} else if index >= 16 {
+			r, g, b := index256ToRGB(index)
+			index = rgbTo16(r, g, b)
+		}
+		if index >= 8 {
+			return appendParam(b, n, base+60+int(index-8))
+		}
+		return appendParam(b, n, base+int(index))
+	}
+}
+
+// the xterm default palette
+var palette16 = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+func index256ToRGB(n uint8) (r, g, b uint8) {
+	switch {
+	case n < 16:
+		p := palette16[n]
+		return p[0], p[1], p[2]
+	case n < 232:
+		n -= 16
+		return cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]
+	default:
+		v := 8 + (n-232)*10
+		return v, v, v
+	}
+}
+
+func rgbTo256(r, g, b uint8) uint8 {
+	cube := func(v uint8) uint8 {
+		var nearest uint8
+		for i, l := range cubeLevels {
+			if absDiff(v, l) < absDiff(v, cubeLevels[nearest]) {
+				nearest = uint8(i)
+			}
+		}
+		return nearest
+	}
+	ci := 16 + 36*cube(r) + 6*cube(g) + cube(b)
+
+	avg := (int(r) + int(g) + int(b)) / 3
+	gi := uint8(232)
+	if avg > 8 {
+		gi = uint8(min(232+(avg-8+5)/10, 255))
+	}
+
+	cr, cg, cb := index256ToRGB(ci)
+	gr, gg, gb := index256ToRGB(gi)
+	if distance(r, g, b, gr, gg, gb) < distance(r, g, b, cr, cg, cb) {
+		return gi
+	}
+	return ci
+}
+
+func rgbTo16(r, g, b uint8) uint8 {
+	var nearest uint8
+	for i, p := range palette16 {
+		if distance(r, g, b, p[0], p[1], p[2]) < distance(r, g, b, palette16[nearest][0], palette16[nearest][1], palette16[nearest][2]) {
+			nearest = uint8(i)
+		}
+	}
+	return nearest
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func distance(r1, g1, b1, r2, g2, b2 uint8) int {
+	dr, dg, db := absDiff(r1, r2), absDiff(g1, g2), absDiff(b1, b2)
+	return dr*dr + dg*dg + db*db
+}
@@ -0,0 +1,326 @@
+package color
+
+import (
+	"log"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TimeDefault is the layout of log.LstdFlags.
+	TimeDefault = "2006/01/02 15:04:05"
+	// TimeUnixMilli prints milliseconds since the Unix epoch.
+	TimeUnixMilli = "unixmilli"
+	// TimeRelative prints the elapsed time since the handler was made, such as +1.5s.
+	TimeRelative = "relative"
+	// TimeNone prints no time.
+	TimeNone = "-"
+
+	// the format of slog.TextHandler
+	timeCompat = "\x00compat"
+)
+
+type SourceFormat int
+
+const (
+	// SourceLong prints the full path of the file, such as /a/b/c/d.go:23.
+	SourceLong SourceFormat = iota
+	// SourceShort prints the file name, such as d.go:23.
+	SourceShort
+	// SourceRelative prints the path relative to the root of the main module, such as c/d.go:23.
+	// Files of other modules are printed with their import paths, such as log/slog/logger.go:23.
+	SourceRelative
+	// SourceFunc prints the function name, such as c.Func:23.
+	SourceFunc
+)
+
+// appends a space if something is in buf.
+func appendSep(buf []byte) []byte {
+	if len(buf) != 0 {
+		return append(buf, ' ')
+	}
+	return buf
+}
+
+// applies ReplaceAttr to a built-in attr.
+// It returns false if removed.
+func (h *ColorHandler) replaceBuiltin(a slog.Attr) (slog.Attr, bool) {
+	if h.opts.ReplaceAttr == nil {
+		return a, true
+	}
+
+	a = h.opts.ReplaceAttr(nil, a)
+	if a.Equal(slog.Attr{}) {
+		return a, false
+	}
+	a.Value = a.Value.Resolve()
+
+	return a, true
+}
+
+// appends key= if Compat.
+func (h *ColorHandler) appendBuiltinKey(buf []byte, key string) []byte {
+	if h.opts.Compat {
+		start := len(buf)
+		buf = appendTextQuote(buf, key)
+		buf = h.escape(buf, start)
+		buf = append(buf, '=')
+	}
+	return buf
+}
+
+// appends a value replaced by ReplaceAttr.
+func (h *ColorHandler) appendBuiltinValue(buf []byte, v slog.Value) []byte {
+	start := len(buf)
+	if h.opts.Compat {
+		buf = appendTextValue(buf, v)
+	} else {
+		buf = append(buf, v.String()...)
+	}
+	return h.escape(buf, start)
+}
+
+func (h *ColorHandler) appendTime(buf []byte, t time.Time, flags int) []byte {
+	format := h.opts.TimeFormat
+	utc := h.opts.TimeUTC
+	if h.opts.FollowLogFlags {
+		format = flagsTimeFormat(flags)
+		utc = flags&log.LUTC != 0
+	}
+	if format == "" || format == TimeNone {
+		return buf
+	}
+	if h.opts.Compat {
+		format = timeCompat
+	}
+
+	a, ok := h.replaceBuiltin(slog.Time(slog.TimeKey, t.Round(0)))
+	if !ok {
+		return buf
+	}
+
+	buf = appendSep(buf)
+	buf = h.appendBuiltinKey(buf, a.Key)
+
+	tm := h.scheme.TimePrinter()
+	buf = tm.AppendFormat(buf)
+	if a.Value.Kind() != slog.KindTime {
+		buf = h.appendBuiltinValue(buf, a.Value)
+		return tm.AppendUnformat(buf)
+	}
+
+	t = a.Value.Time()
+	if utc {
+		t = t.UTC()
+	}
+	start := len(buf)
+	switch format {
+	case timeCompat:
+		buf = appendRFC3339Millis(buf, t)
+	case TimeUnixMilli:
+		buf = strconv.AppendInt(buf, t.UnixMilli(), 10)
+	case TimeRelative:
+		buf = append(buf, '+')
+		buf = append(buf, t.Sub(h.start).Round(time.Millisecond).String()...)
+	default:
+		buf = t.AppendFormat(buf, format)
+	}
+	buf = h.escape(buf, start)
+	buf = tm.AppendUnformat(buf)
+
+	return buf
+}
+
+func (h *ColorHandler) appendLevel(buf []byte, level slog.Level) []byte {
+	a, ok := h.replaceBuiltin(slog.Any(slog.LevelKey, level))
+	if !ok {
+		return buf
+	}
+
+	buf = appendSep(buf)
+	buf = h.appendBuiltinKey(buf, a.Key)
+
+	start := len(buf)
+	lvl := h.scheme.LevelPrinter(level)
+	buf = lvl.AppendFormat(buf)
+	if l, ok := a.Value.Any().(slog.Level); ok && a.Value.Kind() == slog.KindAny {
+		label := len(buf)
+		buf = append(buf, h.levelString(l)...)
+		buf = h.escape(buf, label)
+	} else {
+		buf = h.appendBuiltinValue(buf, a.Value)
+	}
+	buf = lvl.AppendUnformat(buf)
+	buf = h.padLevel(buf, start)
+
+	return buf
+}
+
+func (h *ColorHandler) appendMessage(buf []byte, message string) []byte {
+	a, ok := h.replaceBuiltin(slog.String(slog.MessageKey, message))
+	if !ok {
+		return buf
+	}
+
+	buf = appendSep(buf)
+	buf = h.appendBuiltinKey(buf, a.Key)
+
+	msg := h.scheme.MessagePrinter()
+	buf = msg.AppendFormat(buf)
+	buf = h.appendBuiltinValue(buf, a.Value)
+	buf = msg.AppendUnformat(buf)
+
+	return buf
+}
+
+// returns the layout of flags, or "" if no time.
+func flagsTimeFormat(flags int) string {
+	return flagsTimeFormats[flags&(log.Ldate|log.Ltime|log.Lmicroseconds)]
+}
+
+// layouts for all combinations of log.Ldate, log.Ltime and log.Lmicroseconds
+var flagsTimeFormats = func() [log.Lmicroseconds << 1]string {
+	var formats [log.Lmicroseconds << 1]string
+
+	for flags := range formats {
+		var format string
+		if flags&log.Ldate != 0 {
+			format = "2006/01/02"
+		}
+		if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+			if format != "" {
+				format += " "
+			}
+
+			format += "15:04:05"
+			if flags&log.Lmicroseconds != 0 {
+				format += ".000000"
+			}
+		}
+		formats[flags] = format
+	}
+
+	return formats
+}()
+
+func (h *ColorHandler) appendSource(buf []byte, pc uintptr, flags int) []byte {
+	addSource := h.opts.AddSource
+	format := h.opts.SourceFormat
+	if h.opts.FollowLogFlags {
+		flagshortfile := flags&log.Lshortfile != 0
+		flaglongfile := flags&log.Llongfile != 0
+
+		addSource = addSource || flagshortfile || flaglongfile
+		if h.opts.AddSource || flaglongfile {
+			format = SourceLong
+		} else {
+			format = SourceShort
+		}
+	}
+	if !addSource {
+		return buf
+	}
+
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+
+	a, ok := h.replaceBuiltin(slog.Any(slog.SourceKey, &slog.Source{
+		Function: f.Function,
+		File:     f.File,
+		Line:     f.Line,
+	}))
+	if !ok {
+		return buf
+	}
+
+	buf = appendSep(buf)
+	buf = h.appendBuiltinKey(buf, a.Key)
+
+	src := h.scheme.SourcePrinter()
+	buf = src.AppendFormat(buf)
+
+	source, ok := a.Value.Any().(*slog.Source)
+	if !ok || a.Value.Kind() != slog.KindAny || source == nil {
+		buf = h.appendBuiltinValue(buf, a.Value)
+	} else {
+		var file string
+		switch format {
+		case SourceShort:
+			file = filepath.Base(source.File)
+		case SourceRelative:
+			file = moduleRelative(source)
+		case SourceFunc:
+			file = source.Function
+			if idx := strings.LastIndexByte(file, '/'); idx != -1 {
+				file = file[idx+1:]
+			}
+		default:
+			file = source.File
+		}
+
+		start := len(buf)
+		if h.opts.Compat {
+			buf = appendTextQuote(buf, file+":"+strconv.Itoa(source.Line))
+		} else {
+			buf = append(buf, file...)
+			buf = append(buf, ':')
+			buf = strconv.AppendInt(buf, int64(source.Line), 10)
+		}
+		buf = h.escape(buf, start)
+	}
+
+	if !h.opts.Compat {
+		buf = append(buf, ':')
+	}
+	buf = src.AppendUnformat(buf)
+
+	return buf
+}
+
+// the main module and the main package of the binary
+var modulePath, mainPackage = func() (string, string) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", ""
+	}
+	return bi.Main.Path, bi.Path
+}()
+
+// returns the path of source.File relative to the root of the main module,
+// or the import path of the package and the file name if not in the module.
+func moduleRelative(source *slog.Source) string {
+	pkg := funcPackage(source.Function)
+	if pkg == "main" {
+		pkg = mainPackage
+	}
+	if pkg == "" {
+		return source.File
+	}
+
+	file := filepath.Base(source.File)
+	if modulePath != "" {
+		if pkg == modulePath {
+			return file
+		}
+		if dir, found := strings.CutPrefix(pkg, modulePath+"/"); found {
+			return dir + "/" + file
+		}
+	}
+	return pkg + "/" + file
+}
+
+// returns the import path of the package of a function name such as a/b.(*T).M,
+// without the suffix _test of external test packages.
+func funcPackage(name string) string {
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot == -1 {
+		return ""
+	}
+	return strings.TrimSuffix(name[:slash+1+dot], "_test")
+}
@@ -0,0 +1,256 @@
+package color
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// HTMLColor is a Colorizer of <span>.
+type HTMLColor struct {
+	// Class is the class attribute, such as "lvl-error".
+	Class string
+	// Style is the style attribute, such as "color:#cd0000;font-weight:bold".
+	Style string
+}
+
+func (c *HTMLColor) AppendFormat(b []byte) []byte {
+	b = append(b, "<span"...)
+	if c.Class != "" {
+		b = append(b, ` class="`...)
+		b = appendHTMLEscape(b, c.Class)
+		b = append(b, '"')
+	}
+	if c.Style != "" {
+		b = append(b, ` style="`...)
+		b = appendHTMLEscape(b, c.Style)
+		b = append(b, '"')
+	}
+	return append(b, '>')
+}
+
+func (c *HTMLColor) AppendUnformat(b []byte) []byte {
+	return append(b, "</span>"...)
+}
+
+// NewHTMLHandler makes a ColorHandler that writes HTML, to be put in <pre>.
+//
+// Colors and ExtColors of scheme are converted to HTMLColors
+// whose classes are the roles in scheme (see StyleSheet),
+// or whose styles are the colors if opts.InlineStyle.
+// Other Colorizers are used as they are.
+//
+// Messages, keys and values are escaped.
+func NewHTMLHandler(w io.Writer, opts *HandlerOptions, scheme *Scheme) *ColorHandler {
+	if scheme == nil {
+		scheme = DefaultDarkScheme()
+	}
+
+	var o HandlerOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	// Colors are disabled by NewHandler if ColorNever
+	s := *scheme
+	if o.ColorMode != ColorNever {
+		s = scheme.htmlScheme(o.InlineStyle)
+	}
+
+	h := NewHandler(w, &o, &s)
+	h.html = true
+
+	return h
+}
+
+// StyleSheet returns CSS for the classes of NewHTMLHandler.
+func StyleSheet(scheme *Scheme) string {
+	var b strings.Builder
+
+	scheme.eachRole(func(class string, c Colorizer) {
+		style := cssStyle(c)
+		if style == "" {
+			return
+		}
+		fmt.Fprintf(&b, ".%s { %s; }\n", class, strings.ReplaceAll(style, ";", "; "))
+	})
+
+	return b.String()
+}
+
+func (s Scheme) htmlScheme(inline bool) Scheme {
+	roles := make(map[Colorizer]string)
+	s.eachRole(func(class string, c Colorizer) {
+		switch c.(type) {
+		case *Color, *ExtColor:
+			if _, found := roles[c]; !found {
+				roles[c] = class
+			}
+		}
+	})
+
+	return s.mapColorizers(func(c Colorizer) Colorizer {
+		switch c.(type) {
+		case *Color, *ExtColor:
+		default:
+			return c
+		}
+
+		if inline {
+			return &HTMLColor{Style: cssStyle(c)}
+		}
+		return &HTMLColor{Class: roles[c]}
+	})
+}
+
+// calls f with the class name and the Colorizer (not nil) of each role.
+func (s Scheme) eachRole(f func(class string, c Colorizer)) {
+	call := func(class string, c Colorizer) {
+		if c != nil {
+			f(class, c)
+		}
+	}
+
+	call("base", s.Base)
+	call("time", s.Time)
+	call("source", s.Source)
+	call("msg", s.Message)
+	call("key", s.AttrKey)
+	call("value", s.AttrValue)
+	call("error", s.Error)
+	call("nil", s.Nil)
+
+	for _, level := range sortedKeys(s.Level) {
+		call(levelClass(level), s.Level[level])
+	}
+	for _, kind := range sortedKeys(s.Kind) {
+		call("kind-"+schemeKindNames[kind], s.Kind[kind])
+	}
+	for i, r := range s.Keys {
+		call("rule"+strconv.Itoa(i)+"-key", r.Key)
+		call("rule"+strconv.Itoa(i)+"-value", r.Value)
+	}
+}
+
+// lvl-info, lvl-warn-plus-2, lvl-debug-minus-4
+func levelClass(level slog.Level) string {
+	name := strings.ToLower(level.String())
+	name = strings.NewReplacer("+", "-plus-", "-", "-minus-").Replace(name)
+	return "lvl-" + name
+}
+
+// returns CSS declarations separated by ';' for Color and ExtColor.
+func cssStyle(c Colorizer) string {
+	var params []color.Attribute
+	var fg, bg ColorSpec
+
+	switch c := c.(type) {
+	case *Color:
+		params = c.Params
+	case *ExtColor:
+		params = c.Attrs
+		fg, bg = c.Fg, c.Bg
+	default:
+		return ""
+	}
+
+	var decls []string
+	var decorations []string
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == color.Bold:
+			decls = append(decls, "font-weight:bold")
+		case p == color.Faint:
+			decls = append(decls, "opacity:0.6")
+		case p == color.Italic:
+			decls = append(decls, "font-style:italic")
+		case p == color.Underline:
+			decorations = append(decorations, "underline")
+		case p == color.BlinkSlow || p == color.BlinkRapid:
+			decorations = append(decorations, "blink")
+		case p == color.CrossedOut:
+			decorations = append(decorations, "line-through")
+		case p == color.Concealed:
+			decls = append(decls, "visibility:hidden")
+		case p >= color.FgBlack && p <= color.FgWhite:
+			fg = Index256(uint8(p - color.FgBlack))
+		case p >= color.FgHiBlack && p <= color.FgHiWhite:
+			fg = Index256(uint8(p-color.FgHiBlack) + 8)
+		case p >= color.BgBlack && p <= color.BgWhite:
+			bg = Index256(uint8(p - color.BgBlack))
+		case p >= color.BgHiBlack && p <= color.BgHiWhite:
+			bg = Index256(uint8(p-color.BgHiBlack) + 8)
+		case p == 38 || p == 48:
+			// 38;5;n or 38;2;r;g;b
+			var spec ColorSpec
+			if i+2 < len(params) && params[i+1] == 5 {
+				spec = Index256(uint8(params[i+2]))
+				i += 2
+			} else if i+4 < len(params) && params[i+1] == 2 {
+				spec = RGB(uint8(params[i+2]), uint8(params[i+3]), uint8(params[i+4]))
+				i += 4
+			}
+			if p == 38 {
+				fg = spec
+			} else {
+				bg = spec
+			}
+		}
+	}
+
+	if !fg.IsZero() {
+		decls = append(decls, "color:"+fg.css())
+	}
+	if !bg.IsZero() {
+		decls = append(decls, "background-color:"+bg.css())
+	}
+	if len(decorations) != 0 {
+		decls = append(decls, "text-decoration:"+strings.Join(decorations, " "))
+	}
+	return strings.Join(decls, ";")
+}
+
+// #rrggbb
+func (c ColorSpec) css() string {
+	if c.kind == specIndex {
+		r, g, b := index256ToRGB(c.index)
+		return RGB(r, g, b).String()
+	}
+	return c.String()
+}
+
+// escapes buf[start:] if h is made by NewHTMLHandler.
+func (h *ColorHandler) escape(buf []byte, start int) []byte {
+	if !h.html || bytes.IndexAny(buf[start:], `<>&"'`) == -1 {
+		return buf
+	}
+
+	text := string(buf[start:])
+	return appendHTMLEscape(buf[:start], text)
+}
+
+func appendHTMLEscape(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '<':
+			b = append(b, "&lt;"...)
+		case '>':
+			b = append(b, "&gt;"...)
+		case '&':
+			b = append(b, "&amp;"...)
+		case '"':
+			b = append(b, "&#34;"...)
+		case '\'':
+			b = append(b, "&#39;"...)
+		default:
+			b = append(b, s[i])
+		}
+	}
+	return b
+}
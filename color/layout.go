@@ -0,0 +1,329 @@
+package color
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type LevelFormat int
+
+const (
+	// LevelDefault prints level.String(), such as INFO and WARN+2.
+	LevelDefault LevelFormat = iota
+	// LevelPadded pads level.String() to 5 columns, such as "INFO ".
+	LevelPadded
+	// LevelShort prints 3 letters, such as DBG, INF, WRN and ERR (WRN+2 for levels between).
+	LevelShort
+)
+
+// WidthTerminal is HandlerOptions.Width of the terminal of the writer, or $COLUMNS.
+// It is checked at most once a second, following resizes.
+const WidthTerminal = -1
+
+// ellipsis of truncated attrs
+const ellipsis = " …"
+
+func (h *ColorHandler) levelString(level slog.Level) string {
+	if h.opts.Compat {
+		return level.String()
+	}
+
+	labeled, label, found := h.scheme.levelLabel(level)
+	if !found {
+		return h.builtinLevelString(level)
+	}
+
+	text := label.Text
+	if text == "" {
+		text = h.builtinLevelString(labeled)
+	}
+	text += levelOffset(level - labeled)
+	if label.Icon != "" {
+		text = label.Icon + " " + text
+	}
+	return text
+}
+
+// such as +2, -4 or ""
+func levelOffset(val slog.Level) string {
+	switch {
+	case val > 0:
+		return "+" + strconv.Itoa(int(val))
+	case val < 0:
+		return strconv.Itoa(int(val))
+	default:
+		return ""
+	}
+}
+
+func (h *ColorHandler) builtinLevelString(level slog.Level) string {
+	if h.opts.LevelFormat != LevelShort {
+		return level.String()
+	}
+
+	switch {
+	case level < slog.LevelInfo:
+		return "DBG" + levelOffset(level-slog.LevelDebug)
+	case level < slog.LevelWarn:
+		return "INF" + levelOffset(level-slog.LevelInfo)
+	case level < slog.LevelError:
+		return "WRN" + levelOffset(level-slog.LevelWarn)
+	default:
+		return "ERR" + levelOffset(level-slog.LevelError)
+	}
+}
+
+// pads the level in buf[start:].
+func (h *ColorHandler) padLevel(buf []byte, start int) []byte {
+	if h.opts.Compat {
+		return buf
+	}
+
+	var width int
+	switch h.opts.LevelFormat {
+	case LevelPadded:
+		width = len("ERROR")
+	case LevelShort:
+		width = len("ERR")
+	default:
+		return buf
+	}
+
+	return appendPad(buf, width-h.width(buf[start:]))
+}
+
+// pads the message in buf[start:] to MessageWidth.
+func (h *ColorHandler) padMessage(buf []byte, start int) []byte {
+	if h.opts.MessageWidth <= 0 || h.opts.Compat || h.multiline() || len(buf) == start {
+		return buf
+	}
+
+	width := h.width(buf[start:])
+	if start != 0 {
+		// the separator
+		width--
+	}
+	return appendPad(buf, h.opts.MessageWidth-width)
+}
+
+func appendPad(buf []byte, n int) []byte {
+	for ; n > 0; n-- {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+// returns the width of a line, 0 if not limited.
+func (h *ColorHandler) lineWidth() int {
+	if h.opts.Compat || h.multiline() {
+		return 0
+	}
+	if h.opts.Width != WidthTerminal {
+		return max(h.opts.Width, 0)
+	}
+	return h.term.get()
+}
+
+// refresh interval of termWidth
+const termWidthInterval = time.Second
+
+// termWidth is the width of the terminal of w, or $COLUMNS,
+// checked at most once per termWidthInterval not to make a syscall per record.
+type termWidth struct {
+	w       io.Writer
+	width   atomic.Int64
+	checked atomic.Int64 // UnixNano
+}
+
+func newTermWidth(w io.Writer) *termWidth {
+	t := &termWidth{w: w}
+	t.refresh(time.Now().UnixNano())
+	return t
+}
+
+func (t *termWidth) get() int {
+	now := time.Now().UnixNano()
+	if now-t.checked.Load() >= int64(termWidthInterval) {
+		t.refresh(now)
+	}
+	return int(t.width.Load())
+}
+
+func (t *termWidth) refresh(now int64) {
+	t.checked.Store(now)
+
+	if f, ok := t.w.(interface{ Fd() uintptr }); ok {
+		if width := terminalWidth(f.Fd()); width > 0 {
+			t.width.Store(int64(width))
+			return
+		}
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		t.width.Store(int64(width))
+		return
+	}
+	t.width.Store(0)
+}
+
+// fits the line in buf into width,
+// wrapping or truncating attrs in buf[attrsStart:] at their boundaries.
+func (h *ColorHandler) fitWidth(buf []byte, attrsStart, width int) []byte {
+	if width <= 0 || attrsStart == len(buf) || h.width(buf) <= width {
+		return buf
+	}
+
+	attrs := slices.Clone(buf[attrsStart:])
+	bounds := h.attrBounds(attrs)
+	if len(bounds) == 0 || bounds[0] != 0 {
+		bounds = append([]int{0}, bounds...)
+	}
+
+	buf = buf[:attrsStart]
+	lineWidth := h.width(buf)
+	for i, start := range bounds {
+		end := len(attrs)
+		if i+1 < len(bounds) {
+			end = bounds[i+1]
+		}
+		seg := attrs[start:end]
+		if len(seg) == 0 {
+			continue
+		}
+		segWidth := h.width(seg)
+
+		if h.opts.Wrap {
+			// seg starts with ' '
+			if lineWidth+segWidth > width && lineWidth > len(indentUnit) {
+				buf = append(buf, '\n')
+				buf = append(buf, indentUnit...)
+				buf = append(buf, seg[1:]...)
+				lineWidth = len(indentUnit) + segWidth - 1
+				continue
+			}
+		} else {
+			need := segWidth
+			if i+1 < len(bounds) {
+				need += utf8.RuneCountInString(ellipsis)
+			}
+			if lineWidth+need > width {
+				return append(buf, ellipsis...)
+			}
+		}
+
+		buf = append(buf, seg...)
+		lineWidth += segWidth
+	}
+
+	return buf
+}
+
+// returns the offsets of spaces before attrs,
+// skipping quoted strings and escape sequences.
+func (h *ColorHandler) attrBounds(b []byte) []int {
+	var bounds []int
+
+	quoted := false
+	for i := 0; i < len(b); i++ {
+		switch {
+		case b[i] == '\x1b':
+			i = skipEscape(b, i)
+		case h.html && b[i] == '<':
+			for i < len(b) && b[i] != '>' {
+				i++
+			}
+		case quoted && b[i] == '\\':
+			i++
+			if h.html && hasPrefixAt(b, i, "&#34;") {
+				i += len("&#34;") - 1
+			}
+		case b[i] == '"', h.html && hasPrefixAt(b, i, "&#34;"):
+			quoted = !quoted
+		case !quoted && b[i] == ' ':
+			bounds = append(bounds, i)
+		}
+	}
+
+	return bounds
+}
+
+func hasPrefixAt(b []byte, i int, prefix string) bool {
+	return i < len(b) && len(b)-i >= len(prefix) && string(b[i:i+len(prefix)]) == prefix
+}
+
+// returns the offset of the last byte of the escape sequence at b[i].
+func skipEscape(b []byte, i int) int {
+	if i+1 < len(b) && b[i+1] == '[' {
+		for i += 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return i
+			}
+		}
+		return len(b) - 1
+	}
+	return min(i+1, len(b)-1)
+}
+
+// returns the columns of b on a terminal (or in a browser if html),
+// ignoring escape sequences (or tags).
+func (h *ColorHandler) width(b []byte) int {
+	width := 0
+	for i := 0; i < len(b); {
+		switch {
+		case b[i] == '\x1b':
+			i = skipEscape(b, i) + 1
+			continue
+		case h.html && b[i] == '<':
+			for i < len(b) && b[i] != '>' {
+				i++
+			}
+			i++
+			continue
+		case h.html && b[i] == '&':
+			for i < len(b) && b[i] != ';' {
+				i++
+			}
+			i++
+			width++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(b[i:])
+		width += runeWidth(r)
+		i += size
+	}
+	return width
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r < ' ' || r == 0x7f:
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case isWide(r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// East Asian Wide and Fullwidth, and emojis
+func isWide(r rune) bool {
+	return r >= 0x1100 && r <= 0x115f ||
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f ||
+		r >= 0xac00 && r <= 0xd7a3 ||
+		r >= 0xf900 && r <= 0xfaff ||
+		r >= 0xfe30 && r <= 0xfe4f ||
+		r >= 0xff00 && r <= 0xff60 ||
+		r >= 0xffe0 && r <= 0xffe6 ||
+		r >= 0x1f300 && r <= 0x1f64f ||
+		r >= 0x1f900 && r <= 0x1f9ff ||
+		r >= 0x20000 && r <= 0x3fffd
+}
@@ -0,0 +1,146 @@
+package color
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const indentUnit = "  "
+
+func (h *ColorHandler) multiline() bool {
+	return h.opts.Multiline && !h.opts.Compat
+}
+
+// appends a new line and the indent for an attr in depth.
+func appendIndent(buf []byte, depth int) []byte {
+	buf = append(buf, '\n')
+	for i := 0; i <= depth; i++ {
+		buf = append(buf, indentUnit...)
+	}
+	return buf
+}
+
+// appends headers of the groups not in h.attrs yet.
+func (h *ColorHandler) appendGroupHeaders(buf []byte) []byte {
+	for i := h.openGroups; i < len(h.groups); i++ {
+		buf = h.appendGroupHeader(buf, i, h.groups[i])
+	}
+	return buf
+}
+
+func (h *ColorHandler) appendGroupHeader(buf []byte, depth int, name string) []byte {
+	pk := h.scheme.AttrKeyPrinter()
+	pb := h.scheme.BasePrinter()
+
+	buf = appendIndent(buf, depth)
+	buf = pk.AppendFormat(buf)
+	start := len(buf)
+	buf = append(buf, name...)
+	buf = h.escape(buf, start)
+	buf = pk.AppendUnformat(buf)
+	buf = pb.AppendFormat(buf)
+	buf = append(buf, ':')
+	buf = pb.AppendUnformat(buf)
+
+	return buf
+}
+
+// appends an attr on its own line, a group as an indented block.
+// The depth of the attr is len(groups).
+func (h *ColorHandler) appendAttrBlock(buf []byte, groups []string, a slog.Attr) []byte {
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key == "" {
+			for _, child := range a.Value.Group() {
+				buf = h.appendAttrBlock(buf, groups, child)
+			}
+			return buf
+		}
+
+		cgroups := append(slices.Clip(groups), a.Key)
+		var block []byte
+		for _, child := range a.Value.Group() {
+			block = h.appendAttrBlock(block, cgroups, child)
+		}
+		// empty groups are omitted
+		if len(block) == 0 {
+			return buf
+		}
+
+		buf = h.appendGroupHeader(buf, len(groups), a.Key)
+		return append(buf, block...)
+	}
+
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		if a.Equal(slog.Attr{}) {
+			return buf
+		}
+		a.Value = a.Value.Resolve()
+	}
+
+	var prefix string
+	if len(h.scheme.Keys) != 0 {
+		prefix = strings.Join(groups, ".")
+	}
+	pk, pv := h.scheme.AttrPrinters(prefix, a.Key, a.Value)
+	pb := h.scheme.BasePrinter()
+
+	buf = appendIndent(buf, len(groups))
+
+	buf = pk.AppendFormat(buf)
+	start := len(buf)
+	buf = h.appendKey(buf, a.Key)
+	buf = h.escape(buf, start)
+	buf = pk.AppendUnformat(buf)
+
+	buf = pb.AppendFormat(buf)
+	buf = append(buf, '=')
+	buf = pb.AppendUnformat(buf)
+
+	buf = pv.AppendFormat(buf)
+	start = len(buf)
+	buf = appendPrettyValue(buf, a.Value, len(groups))
+	buf = h.escape(buf, start)
+	buf = pv.AppendUnformat(buf)
+
+	return buf
+}
+
+// appends maps, slices and structs as indented JSON, others as they are.
+func appendPrettyValue(buf []byte, v slog.Value, depth int) []byte {
+	if v.Kind() == slog.KindAny && isComposite(v.Any()) {
+		prefix := strings.Repeat(indentUnit, depth+1)
+		if b, err := json.MarshalIndent(v.Any(), prefix, indentUnit); err == nil {
+			return append(buf, b...)
+		}
+	}
+	return appendQuote(buf, v.String())
+}
+
+func isComposite(v any) bool {
+	switch v.(type) {
+	case error, fmt.Stringer, []byte:
+		return false
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package color
+
+import (
+	"encoding"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// appends s, enclosed in '"' if it has spaces, tabs or '"'.
+func appendQuote(b []byte, s string) []byte {
+	if strings.ContainsAny(s, " \t\"") {
+		b = append(b, '"')
+		b = append(b, s...)
+		b = append(b, '"')
+		return b
+	}
+	return append(b, s...)
+}
+
+// appends a key, quoted as slog.TextHandler if Compat.
+func (h *ColorHandler) appendKey(b []byte, key string) []byte {
+	if h.opts.Compat {
+		return appendTextQuote(b, key)
+	}
+	return append(b, key...)
+}
+
+// appends v, formatted as slog.TextHandler if Compat.
+func (h *ColorHandler) appendValue(b []byte, v slog.Value) []byte {
+	if h.opts.Compat {
+		return appendTextValue(b, v)
+	}
+	return appendQuote(b, v.String())
+}
+
+// Quoting and value formatting below are the same as slog.TextHandler.
+
+// appends s, quoted by strconv.Quote if needed.
+func appendTextQuote(b []byte, s string) []byte {
+	if needsQuoting(s) {
+		return strconv.AppendQuote(b, s)
+	}
+	return append(b, s...)
+}
+
+func needsQuoting(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			// control characters, space, '"' and '='
+			if b <= ' ' || b == '"' || b == '=' {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+// appends v formatted as slog.TextHandler.
+func appendTextValue(b []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendTextQuote(b, v.String())
+	case slog.KindTime:
+		return appendRFC3339Millis(b, v.Time())
+	case slog.KindAny:
+		if _, ok := v.Any().(encoding.TextMarshaler); !ok {
+			// always quoted
+			if bs, ok := byteSlice(v.Any()); ok {
+				return strconv.AppendQuote(b, string(bs))
+			}
+		}
+		return appendTextQuote(b, anyString(v.Any()))
+	default:
+		return append(b, v.String()...)
+	}
+}
+
+func anyString(v any) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			// a nil pointer of encoding.TextMarshaler or error
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+				s = "<nil>"
+				return
+			}
+			s = fmt.Sprintf("!PANIC: %v", r)
+		}
+	}()
+
+	if tm, ok := v.(encoding.TextMarshaler); ok {
+		data, err := tm.MarshalText()
+		if err != nil {
+			return fmt.Sprintf("!ERROR:%v", err)
+		}
+		return string(data)
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
+// []byte or a named type of it
+func byteSlice(v any) ([]byte, bool) {
+	if bs, ok := v.([]byte); ok {
+		return bs, true
+	}
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return reflect.ValueOf(v).Bytes(), true
+	}
+	return nil, false
+}
+
+// the time format of slog.TextHandler
+func appendRFC3339Millis(b []byte, t time.Time) []byte {
+	// RFC3339Nano trims trailing 0s, so add 1/10 millisecond
+	// to guarantee that there are exactly 4 digits after the period.
+	const prefixLen = len("2006-01-02T15:04:05.000")
+	n := len(b)
+	t = t.Truncate(time.Millisecond).Add(time.Millisecond / 10)
+	b = t.AppendFormat(b, time.RFC3339Nano)
+	b = append(b[:n+prefixLen], b[n+prefixLen+1:]...) // drop the 4th digit
+	return b
+}
@@ -2,6 +2,9 @@ package color
 
 import (
 	"log/slog"
+	"path"
+	"regexp"
+	"strings"
 
 	"github.com/fatih/color"
 )
@@ -11,23 +14,138 @@ import (
 type Scheme struct {
 	Base Colorizer
 
+	// Level colors levels.
+	// A level not in Level is colored by the nearest lower one, such as LevelError+1 by LevelError.
+	// So a custom level also colors the built-in levels above it that are not in Level,
+	// such as WARN and ERROR by {2: notice} (they were not colored before).
 	Level     map[slog.Level]Colorizer
 	Time      Colorizer
 	Source    Colorizer
 	Message   Colorizer
 	AttrKey   Colorizer
 	AttrValue Colorizer
+
+	// Kind colors attr values by their kind.
+	Kind map[slog.Kind]Colorizer
+	// Error colors attr values implementing error.
+	Error Colorizer
+	// Nil colors nil attr values.
+	Nil Colorizer
+
+	// Keys colors attrs whose keys match, prior to the others.
+	// The first matched rule is used.
+	Keys []KeyRule
+
+	// Labels prints levels, such as {-8: {Text: "TRACE"}, 12: {Text: "FATAL", Icon: "💀"}}.
+	// A level between labels is printed as the nearest lower one with the offset, such as FATAL+2.
+	// It is ignored if Compat.
+	Labels map[slog.Level]LevelLabel
+}
+
+// LevelLabel is how a level is printed.
+type LevelLabel struct {
+	// Text is the name of the level, or level.String() if empty.
+	Text string
+	// Icon is printed before Text, such as an emoji.
+	Icon string
+}
+
+// KeyRule colors attrs whose keys match Pattern or Regexp.
+type KeyRule struct {
+	// Pattern is an exact key or a pattern of path.Match.
+	// A pattern without '.' matches the key itself in any group, such as "err".
+	// A pattern with '.' matches the group-prefixed key, such as "http.*".
+	Pattern string
+	// Regexp matches the group-prefixed key.
+	Regexp *regexp.Regexp
+
+	// nil falls back on the printers of Scheme.
+	Key   Colorizer
+	Value Colorizer
+}
+
+func (r KeyRule) match(prefix, key string) bool {
+	if r.Pattern != "" {
+		target := key
+		if strings.Contains(r.Pattern, ".") && prefix != "" {
+			target = prefix + "." + key
+		}
+		if matched, _ := path.Match(r.Pattern, target); matched {
+			return true
+		}
+	}
+
+	if r.Regexp != nil {
+		target := key
+		if prefix != "" {
+			target = prefix + "." + key
+		}
+		if r.Regexp.MatchString(target) {
+			return true
+		}
+	}
+
+	return false
 }
 
+// LevelPrinter returns the Colorizer of the nearest lower level in Level,
+// or BasePrinter() if level is lower than any.
 func (s Scheme) LevelPrinter(level slog.Level) Colorizer {
 	if lp, found := s.Level[level]; found {
 		if lp != nil {
 			return lp
 		}
 	}
+
+	var nearest Colorizer
+	var nearestLevel slog.Level
+	for l, lp := range s.Level {
+		if lp != nil && l <= level && (nearest == nil || l > nearestLevel) {
+			nearest, nearestLevel = lp, l
+		}
+	}
+	if nearest != nil {
+		return nearest
+	}
+
 	return s.BasePrinter()
 }
 
+// levelLabel returns the label of the nearest lower level of Labels or the built-in levels.
+// It returns false if the nearest one is a built-in level not in Labels.
+func (s Scheme) levelLabel(level slog.Level) (labeled slog.Level, label LevelLabel, found bool) {
+	if len(s.Labels) == 0 {
+		return 0, LevelLabel{}, false
+	}
+
+	nearest, lowest := level, level
+	hasNearest, hasLowest := false, false
+	check := func(l slog.Level) {
+		if l <= level && (!hasNearest || l > nearest) {
+			nearest, hasNearest = l, true
+		}
+		if !hasLowest || l < lowest {
+			lowest, hasLowest = l, true
+		}
+	}
+	for l := range s.Labels {
+		check(l)
+	}
+	for _, l := range builtinLevels {
+		check(l)
+	}
+
+	// lower than any, such as TRACE-2
+	if !hasNearest {
+		nearest = lowest
+	}
+
+	label, found = s.Labels[nearest]
+	return nearest, label, found
+}
+
+var builtinLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
 func (s Scheme) TimePrinter() Colorizer {
 	if s.Time != nil {
 		return s.Time
@@ -63,6 +181,51 @@ func (s Scheme) AttrValuePrinter() Colorizer {
 	return s.BasePrinter()
 }
 
+// ValuePrinter returns a Colorizer for an attr value.
+//
+// It falls back on Kind, then AttrValuePrinter().
+func (s Scheme) ValuePrinter(v slog.Value) Colorizer {
+	if v.Kind() == slog.KindAny {
+		switch v.Any().(type) {
+		case nil:
+			if s.Nil != nil {
+				return s.Nil
+			}
+		case error:
+			if s.Error != nil {
+				return s.Error
+			}
+		}
+	}
+	if kp, found := s.Kind[v.Kind()]; found {
+		if kp != nil {
+			return kp
+		}
+	}
+	return s.AttrValuePrinter()
+}
+
+// AttrPrinters returns Colorizers for an attr key and value.
+//
+// prefix is the groups of the attr joined with '.'.
+// Keys are searched first, then AttrKeyPrinter() and ValuePrinter().
+func (s Scheme) AttrPrinters(prefix, key string, v slog.Value) (kp, vp Colorizer) {
+	for _, r := range s.Keys {
+		if r.match(prefix, key) {
+			kp, vp = r.Key, r.Value
+			break
+		}
+	}
+
+	if kp == nil {
+		kp = s.AttrKeyPrinter()
+	}
+	if vp == nil {
+		vp = s.ValuePrinter(v)
+	}
+	return kp, vp
+}
+
 func (s Scheme) BasePrinter() Colorizer {
 	if s.Base != nil {
 		return s.Base
@@ -87,6 +250,16 @@ func DefaultLightScheme() *Scheme {
 			slog.LevelError: NewColor(color.FgRed, color.Bold),
 			slog.LevelDebug: NewColor(color.FgBlack),
 		},
+		Kind: map[slog.Kind]Colorizer{
+			slog.KindInt64:    NewColor(color.FgMagenta),
+			slog.KindUint64:   NewColor(color.FgMagenta),
+			slog.KindFloat64:  NewColor(color.FgMagenta),
+			slog.KindBool:     NewColor(color.FgCyan),
+			slog.KindDuration: NewColor(color.FgGreen),
+			slog.KindTime:     NewColor(color.FgGreen),
+		},
+		Error: NewColor(color.FgRed, color.Bold),
+		Nil:   NewColor(color.FgHiBlack),
 	}
 }
 
@@ -103,5 +276,15 @@ func DefaultDarkScheme() *Scheme {
 			slog.LevelError: NewColor(color.FgHiRed, color.Bold),
 			slog.LevelDebug: NewColor(color.FgWhite, color.Faint),
 		},
+		Kind: map[slog.Kind]Colorizer{
+			slog.KindInt64:    NewColor(color.FgHiMagenta),
+			slog.KindUint64:   NewColor(color.FgHiMagenta),
+			slog.KindFloat64:  NewColor(color.FgHiMagenta),
+			slog.KindBool:     NewColor(color.FgHiYellow),
+			slog.KindDuration: NewColor(color.FgHiGreen),
+			slog.KindTime:     NewColor(color.FgGreen),
+		},
+		Error: NewColor(color.FgHiRed, color.Bold),
+		Nil:   NewColor(color.FgWhite, color.Faint),
 	}
 }
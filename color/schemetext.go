@@ -0,0 +1,559 @@
+package color
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// EnvScheme is the environment variable read by SchemeFromEnv.
+const EnvScheme = "SHANDLER_COLORS"
+
+// ParseScheme parses text on base.
+// Entries not in text are left as base.
+// If base is nil, DefaultNilScheme() is used.
+//
+// text is a list of entries separated by ':' or newlines,
+// like LS_COLORS or GREP_COLORS:
+//
+//	level.error=hired,bold:key=cyan:msg=white
+//
+// An entry is name=attrs.
+// Names are:
+//
+//	base, time, source, msg, key, value, error, nil
+//	level.LEVEL  (such as level.error, level.warn+2, level.-8)
+//	kind.KIND    (int, uint, float, bool, duration, time, string, any)
+//	key[PATTERN], value[PATTERN]  (KeyRule; /REGEXP/ for Regexp)
+//
+// Labels are label.LEVEL=TEXT and icon.LEVEL=ICON (such as label.12=FATAL).
+//
+// Attrs are separated by ',':
+//
+//	black, red, green, yellow, blue, magenta, cyan, white
+//	hiblack, ..., hiwhite
+//	bgblack, ..., bgwhite, bghiblack, ..., bghiwhite
+//	bold, faint, italic, underline, blinkslow, blinkrapid, reverse, concealed, crossedout
+//	a number of SGR parameter
+//	#rrggbb, #rgb, @n (256 colors), bg#rrggbb, bg#rgb, bg@n (see ExtColor)
+//	none (no color)
+//
+// Empty attrs unset the entry.
+// Lines starting with '#' are comments.
+// A ':' in a pattern, a label or an icon is written as "\:", such as key[/^http\:/]=blue.
+//
+// Scheme also implements encoding.TextMarshaler and encoding.TextUnmarshaler,
+// so that it can be a string in JSON or other config files.
+func ParseScheme(text string, base *Scheme) (*Scheme, error) {
+	s := DefaultNilScheme()
+	if base != nil {
+		*s = *base
+	}
+
+	if err := s.UnmarshalText([]byte(text)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SchemeFromEnv parses the environment variable SHANDLER_COLORS on base (see ParseScheme).
+// As entries are separated by ':', write a ':' in them as "\:".
+func SchemeFromEnv(base *Scheme) (*Scheme, error) {
+	return ParseScheme(os.Getenv(EnvScheme), base)
+}
+
+// SchemeError is an error in the text of Scheme.
+type SchemeError struct {
+	// Offset is the byte offset of Entry in the text.
+	Offset int
+	Entry  string
+	Msg    string
+}
+
+func (e *SchemeError) Error() string {
+	return fmt.Sprintf("color: scheme: offset %d: %q: %s", e.Offset, e.Entry, e.Msg)
+}
+
+// UnmarshalText overwrites s by the entries in text.
+func (s *Scheme) UnmarshalText(text []byte) error {
+	// not to modify maps shared with other Schemes
+	s.Level = maps.Clone(s.Level)
+	s.Kind = maps.Clone(s.Kind)
+	s.Keys = slices.Clone(s.Keys)
+	s.Labels = maps.Clone(s.Labels)
+
+	offset := 0
+	for _, line := range strings.SplitAfter(string(text), "\n") {
+		lineOffset := offset
+		offset += len(line)
+
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		entryOffset := lineOffset
+		for _, entry := range splitEntries(strings.TrimRight(line, "\r\n")) {
+			eoffset := entryOffset
+			entryOffset += len(entry) + 1
+
+			trimmed := strings.TrimSpace(entry)
+			if trimmed == "" {
+				continue
+			}
+			eoffset += strings.Index(entry, trimmed)
+
+			if err := s.parseEntry(strings.ReplaceAll(trimmed, `\:`, ":")); err != "" {
+				return &SchemeError{Offset: eoffset, Entry: trimmed, Msg: err}
+			}
+		}
+	}
+
+	return nil
+}
+
+// splits line by ':' not escaped as "\:".
+func splitEntries(line string) []string {
+	var entries []string
+
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if i+1 < len(line) && line[i+1] == ':' {
+				i++
+			}
+		case ':':
+			entries = append(entries, line[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(entries, line[start:])
+}
+
+// returns an error message.
+func (s *Scheme) parseEntry(entry string) string {
+	name, attrs, found := strings.Cut(entry, "=")
+	if !found {
+		return "missing '='"
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if strings.HasPrefix(name, "label.") || strings.HasPrefix(name, "icon.") {
+		kind, lname, _ := strings.Cut(name, ".")
+		level, err := parseSchemeLevel(lname)
+		if err != "" {
+			return err
+		}
+		if s.Labels == nil {
+			s.Labels = make(map[slog.Level]LevelLabel)
+		}
+		label := s.Labels[level]
+		if kind == "label" {
+			label.Text = strings.TrimSpace(attrs)
+		} else {
+			label.Icon = strings.TrimSpace(attrs)
+		}
+		if label == (LevelLabel{}) {
+			delete(s.Labels, level)
+		} else {
+			s.Labels[level] = label
+		}
+		return ""
+	}
+
+	c, err := parseColorizer(attrs)
+	if err != "" {
+		return err
+	}
+
+	switch name {
+	case "base":
+		s.Base = c
+	case "time":
+		s.Time = c
+	case "source":
+		s.Source = c
+	case "msg":
+		s.Message = c
+	case "key":
+		s.AttrKey = c
+	case "value":
+		s.AttrValue = c
+	case "error":
+		s.Error = c
+	case "nil":
+		s.Nil = c
+	default:
+		switch {
+		case strings.HasPrefix(name, "level."):
+			level, err := parseSchemeLevel(name[len("level."):])
+			if err != "" {
+				return err
+			}
+			if s.Level == nil {
+				s.Level = make(map[slog.Level]Colorizer)
+			}
+			s.Level[level] = c
+
+		case strings.HasPrefix(name, "kind."):
+			kind, found := schemeKinds[name[len("kind."):]]
+			if !found {
+				return fmt.Sprintf("unknown kind %q", name[len("kind."):])
+			}
+			if s.Kind == nil {
+				s.Kind = make(map[slog.Kind]Colorizer)
+			}
+			s.Kind[kind] = c
+
+		case strings.HasPrefix(name, "key[") || strings.HasPrefix(name, "value["):
+			// the pattern is case-sensitive
+			origName, _, _ := strings.Cut(entry, "=")
+			origName = strings.TrimSpace(origName)
+			open := strings.IndexByte(origName, '[')
+			if !strings.HasSuffix(origName, "]") {
+				return "missing ']'"
+			}
+			pattern := origName[open+1 : len(origName)-1]
+
+			rule, err := s.keyRule(pattern)
+			if err != "" {
+				return err
+			}
+			if strings.HasPrefix(name, "key[") {
+				rule.Key = c
+			} else {
+				rule.Value = c
+			}
+
+		default:
+			return fmt.Sprintf("unknown name %q", name)
+		}
+	}
+
+	return ""
+}
+
+// returns the KeyRule of pattern, appending a new one if not found.
+func (s *Scheme) keyRule(pattern string) (*KeyRule, string) {
+	if pattern == "" {
+		return nil, "empty pattern"
+	}
+
+	for i := range s.Keys {
+		if keyRulePattern(s.Keys[i]) == pattern {
+			return &s.Keys[i], ""
+		}
+	}
+
+	var rule KeyRule
+	if len(pattern) >= 2 && pattern[0] == '/' && pattern[len(pattern)-1] == '/' {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err.Error()
+		}
+		rule.Regexp = re
+	} else {
+		rule.Pattern = pattern
+	}
+	s.Keys = append(s.Keys, rule)
+
+	return &s.Keys[len(s.Keys)-1], ""
+}
+
+func keyRulePattern(r KeyRule) string {
+	if r.Pattern == "" && r.Regexp != nil {
+		return "/" + r.Regexp.String() + "/"
+	}
+	return r.Pattern
+}
+
+func parseSchemeLevel(s string) (slog.Level, string) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return slog.Level(n), ""
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Sprintf("unknown level %q", s)
+	}
+	return level, ""
+}
+
+// returns nil if attrs is empty.
+func parseColorizer(attrs string) (Colorizer, string) {
+	attrs = strings.TrimSpace(attrs)
+	if attrs == "" {
+		return nil, ""
+	}
+	if strings.EqualFold(attrs, "none") {
+		return defaultColorizer, ""
+	}
+
+	var params []color.Attribute
+	var fg, bg ColorSpec
+	for _, a := range strings.Split(attrs, ",") {
+		a = strings.ToLower(strings.TrimSpace(a))
+
+		if strings.HasPrefix(a, "#") || strings.HasPrefix(a, "@") ||
+			strings.HasPrefix(a, "bg#") || strings.HasPrefix(a, "bg@") {
+			spec, isBg, err := parseColorSpec(a)
+			if err != "" {
+				return nil, err
+			}
+			if isBg {
+				bg = spec
+			} else {
+				fg = spec
+			}
+			continue
+		}
+		if p, found := schemeAttributes[a]; found {
+			params = append(params, p)
+			continue
+		}
+		if n, err := strconv.Atoi(a); err == nil && n >= 0 {
+			params = append(params, color.Attribute(n))
+			continue
+		}
+		return nil, fmt.Sprintf("unknown attribute %q", a)
+	}
+
+	if !fg.IsZero() || !bg.IsZero() {
+		return NewExtColor(fg, bg, params...), ""
+	}
+	return NewColor(params...), ""
+}
+
+// parses #rrggbb, #rgb, @n, prefixed by bg optionally.
+func parseColorSpec(a string) (spec ColorSpec, bg bool, err string) {
+	if strings.HasPrefix(a, "bg") {
+		bg = true
+		a = a[len("bg"):]
+	}
+
+	if strings.HasPrefix(a, "@") {
+		n, e := strconv.ParseUint(a[1:], 10, 8)
+		if e != nil {
+			return ColorSpec{}, false, fmt.Sprintf("invalid 256 color %q", a)
+		}
+		return Index256(uint8(n)), bg, ""
+	}
+
+	spec, e := Hex(a)
+	if e != nil {
+		return ColorSpec{}, false, e.Error()
+	}
+	return spec, bg, ""
+}
+
+// MarshalText returns s in the text format.
+//
+// It is an error if s has Colorizers other than *Color and *ExtColor.
+func (s Scheme) MarshalText() ([]byte, error) {
+	var entries []string
+
+	add := func(name string, c Colorizer) error {
+		if c == nil {
+			return nil
+		}
+		attrs, err := marshalColorizer(c)
+		if err != nil {
+			return fmt.Errorf("color: scheme: %s: %w", name, err)
+		}
+		entries = append(entries, name+"="+attrs)
+		return nil
+	}
+
+	for _, e := range []struct {
+		name string
+		c    Colorizer
+	}{
+		{"base", s.Base},
+		{"time", s.Time},
+		{"source", s.Source},
+		{"msg", s.Message},
+		{"key", s.AttrKey},
+		{"value", s.AttrValue},
+		{"error", s.Error},
+		{"nil", s.Nil},
+	} {
+		if err := add(e.name, e.c); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, level := range sortedKeys(s.Level) {
+		if err := add("level."+strings.ToLower(level.String()), s.Level[level]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, level := range sortedKeys(s.Labels) {
+		label := s.Labels[level]
+		for _, e := range []struct{ kind, text string }{{"label", label.Text}, {"icon", label.Icon}} {
+			if e.text == "" {
+				continue
+			}
+			name := e.kind + "." + strings.ToLower(level.String())
+			if strings.Contains(e.text, "\n") || e.text != strings.TrimSpace(e.text) {
+				return nil, fmt.Errorf("color: scheme: %s: unsupported text %q", name, e.text)
+			}
+			entries = append(entries, name+"="+escapeEntry(e.text))
+		}
+	}
+
+	for _, kind := range sortedKeys(s.Kind) {
+		if err := add("kind."+schemeKindNames[kind], s.Kind[kind]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range s.Keys {
+		pattern := keyRulePattern(r)
+		if strings.Contains(pattern, "\n") {
+			return nil, fmt.Errorf("color: scheme: unsupported pattern %q", pattern)
+		}
+		pattern = escapeEntry(pattern)
+		if err := add("key["+pattern+"]", r.Key); err != nil {
+			return nil, err
+		}
+		if err := add("value["+pattern+"]", r.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(strings.Join(entries, ":")), nil
+}
+
+func escapeEntry(s string) string {
+	return strings.ReplaceAll(s, ":", `\:`)
+}
+
+func sortedKeys[K ~int, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func marshalColorizer(c Colorizer) (string, error) {
+	switch c := c.(type) {
+	case *fmtAppender:
+		return "none", nil
+	case *Color:
+		return marshalAttributes(c.Params, nil), nil
+	case *ExtColor:
+		var specs []string
+		if !c.Fg.IsZero() {
+			specs = append(specs, c.Fg.String())
+		}
+		if !c.Bg.IsZero() {
+			specs = append(specs, "bg"+c.Bg.String())
+		}
+		return marshalAttributes(c.Attrs, specs), nil
+	default:
+		return "", fmt.Errorf("unsupported Colorizer %T", c)
+	}
+}
+
+func marshalAttributes(params []color.Attribute, specs []string) string {
+	names := make([]string, 0, len(params)+len(specs))
+	for _, p := range params {
+		if name, found := schemeAttributeNames[p]; found {
+			names = append(names, name)
+		} else {
+			names = append(names, strconv.Itoa(int(p)))
+		}
+	}
+	names = append(names, specs...)
+
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+var schemeAttributes = map[string]color.Attribute{
+	"bold":       color.Bold,
+	"faint":      color.Faint,
+	"italic":     color.Italic,
+	"underline":  color.Underline,
+	"blinkslow":  color.BlinkSlow,
+	"blinkrapid": color.BlinkRapid,
+	"reverse":    color.ReverseVideo,
+	"concealed":  color.Concealed,
+	"crossedout": color.CrossedOut,
+
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+
+	"hiblack":   color.FgHiBlack,
+	"hired":     color.FgHiRed,
+	"higreen":   color.FgHiGreen,
+	"hiyellow":  color.FgHiYellow,
+	"hiblue":    color.FgHiBlue,
+	"himagenta": color.FgHiMagenta,
+	"hicyan":    color.FgHiCyan,
+	"hiwhite":   color.FgHiWhite,
+
+	"bgblack":   color.BgBlack,
+	"bgred":     color.BgRed,
+	"bggreen":   color.BgGreen,
+	"bgyellow":  color.BgYellow,
+	"bgblue":    color.BgBlue,
+	"bgmagenta": color.BgMagenta,
+	"bgcyan":    color.BgCyan,
+	"bgwhite":   color.BgWhite,
+
+	"bghiblack":   color.BgHiBlack,
+	"bghired":     color.BgHiRed,
+	"bghigreen":   color.BgHiGreen,
+	"bghiyellow":  color.BgHiYellow,
+	"bghiblue":    color.BgHiBlue,
+	"bghimagenta": color.BgHiMagenta,
+	"bghicyan":    color.BgHiCyan,
+	"bghiwhite":   color.BgHiWhite,
+}
+
+var schemeAttributeNames = func() map[color.Attribute]string {
+	m := make(map[color.Attribute]string, len(schemeAttributes))
+	for name, a := range schemeAttributes {
+		m[a] = name
+	}
+	return m
+}()
+
+var schemeKinds = map[string]slog.Kind{
+	"int":      slog.KindInt64,
+	"uint":     slog.KindUint64,
+	"float":    slog.KindFloat64,
+	"bool":     slog.KindBool,
+	"duration": slog.KindDuration,
+	"time":     slog.KindTime,
+	"string":   slog.KindString,
+	"any":      slog.KindAny,
+}
+
+var schemeKindNames = func() map[slog.Kind]string {
+	m := make(map[slog.Kind]string, len(schemeKinds))
+	for name, k := range schemeKinds {
+		m[k] = name
+	}
+	return m
+}()
@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package color
+
+func terminalWidth(fd uintptr) int {
+	return 0
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package color
+
+import "golang.org/x/sys/unix"
+
+// returns 0 if fd is not a terminal.
+func terminalWidth(fd uintptr) int {
+	ws, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package color
+
+import "golang.org/x/sys/windows"
+
+// returns 0 if fd is not a console.
+func terminalWidth(fd uintptr) int {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(fd), &info); err != nil {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
@@ -7,9 +7,7 @@ require (
 	github.com/mattn/go-isatty v0.0.20
 	github.com/shu-go/gotwant v0.1.0
 	golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842
+	golang.org/x/sys v0.19.0
 )
 
-require (
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	golang.org/x/sys v0.19.0 // indirect
-)
+require github.com/mattn/go-colorable v0.1.13 // indirect
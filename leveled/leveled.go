@@ -7,39 +7,56 @@ import (
 )
 
 // LeveledHandler has handlers for levels.
+//
+// Each handler is registered for a range of levels.
+// When ranges overlap, the handler registered later wins.
 type LeveledHandler struct {
-	defaultHandler, debugHandler, errorHandler, infoHandler, warnHandler slog.Handler
+	defaultHandler slog.Handler
+	ranges         []levelRange
+}
+
+type levelRange struct {
+	min, max slog.Level
+	handler  slog.Handler
 }
 
 type LeveledOption func(*LeveledHandler)
 
-func Debug(h slog.Handler) func(*LeveledHandler) {
+// Range handles records whose level is in [min, max].
+func Range(min, max slog.Level, h slog.Handler) func(*LeveledHandler) {
 	return func(lh *LeveledHandler) {
-		lh.debugHandler = h
+		if h == nil {
+			return
+		}
+		lh.ranges = append(lh.ranges, levelRange{min: min, max: max, handler: h})
 	}
 }
 
+// Level handles records whose level is exactly level.
+func Level(level slog.Level, h slog.Handler) func(*LeveledHandler) {
+	return Range(level, level, h)
+}
+
+func Debug(h slog.Handler) func(*LeveledHandler) {
+	return Level(slog.LevelDebug, h)
+}
+
 func Error(h slog.Handler) func(*LeveledHandler) {
-	return func(lh *LeveledHandler) {
-		lh.errorHandler = h
-	}
+	return Level(slog.LevelError, h)
 }
 
 func Info(h slog.Handler) func(*LeveledHandler) {
-	return func(lh *LeveledHandler) {
-		lh.infoHandler = h
-	}
+	return Level(slog.LevelInfo, h)
 }
 
 func Warn(h slog.Handler) func(*LeveledHandler) {
-	return func(lh *LeveledHandler) {
-		lh.warnHandler = h
-	}
+	return Level(slog.LevelWarn, h)
 }
 
 // If defaultHandler == nil then slog.NewTextHandler(os.Stdout, nil) is used as a default handler.
 //
-// Use Debug(), Error(), Info(), Warn() to handle each level.
+// Use Debug(), Error(), Info(), Warn() to handle each level,
+// and Level(), Range() to handle any other levels.
 func NewHandler(defaultHandler slog.Handler, lopts ...LeveledOption) *LeveledHandler {
 	h := LeveledHandler{
 		defaultHandler: defaultHandler,
@@ -55,78 +72,52 @@ func NewHandler(defaultHandler slog.Handler, lopts ...LeveledOption) *LeveledHan
 	return &h
 }
 
-// returns some handler is Enabled()
-func (h *LeveledHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	hh := []slog.Handler{
-		h.defaultHandler,
-		h.debugHandler,
-		h.errorHandler,
-		h.infoHandler,
-		h.warnHandler,
-	}
-	for _, he := range hh {
-		if he != nil && he.Enabled(ctx, level) {
-			return true
+// returns the handler for the level.
+func (h *LeveledHandler) handler(level slog.Level) slog.Handler {
+	for i := len(h.ranges) - 1; i >= 0; i-- {
+		if h.ranges[i].min <= level && level <= h.ranges[i].max {
+			return h.ranges[i].handler
 		}
 	}
+	return h.defaultHandler
+}
 
-	return false
+// returns the handler for the level is Enabled()
+func (h *LeveledHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler(level).Enabled(ctx, level)
 }
 
 // applies Handle() to appropriate level handler.
 func (h *LeveledHandler) Handle(ctx context.Context, r slog.Record) error {
-	hh := h.defaultHandler
-	if r.Level == slog.LevelDebug && h.debugHandler != nil {
-		hh = h.debugHandler
-	}
-	if r.Level == slog.LevelError && h.errorHandler != nil {
-		hh = h.errorHandler
-	}
-	if r.Level == slog.LevelInfo && h.infoHandler != nil {
-		hh = h.infoHandler
-	}
-	if r.Level == slog.LevelWarn && h.warnHandler != nil {
-		hh = h.warnHandler
-	}
-
-	return hh.Handle(ctx, r)
+	return h.handler(r.Level).Handle(ctx, r)
 }
 
 // applies WithAttrs() to all handlers.
 func (h *LeveledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	newh := NewHandler(h.defaultHandler.WithAttrs(attrs))
-
-	if h.debugHandler != nil {
-		newh.debugHandler = h.debugHandler.WithAttrs(attrs)
-	}
-	if h.errorHandler != nil {
-		newh.errorHandler = h.errorHandler.WithAttrs(attrs)
-	}
-	if h.infoHandler != nil {
-		newh.infoHandler = h.infoHandler.WithAttrs(attrs)
-	}
-	if h.warnHandler != nil {
-		newh.warnHandler = h.warnHandler.WithAttrs(attrs)
-	}
-
-	return newh
+	return h.with(func(he slog.Handler) slog.Handler {
+		return he.WithAttrs(attrs)
+	})
 }
 
 // applies WithGroup() to all handlers.
 func (h *LeveledHandler) WithGroup(name string) slog.Handler {
-	newh := NewHandler(h.defaultHandler.WithGroup(name))
+	return h.with(func(he slog.Handler) slog.Handler {
+		return he.WithGroup(name)
+	})
+}
 
-	if h.debugHandler != nil {
-		newh.debugHandler = h.debugHandler.WithGroup(name)
-	}
-	if h.errorHandler != nil {
-		newh.errorHandler = h.errorHandler.WithGroup(name)
+func (h *LeveledHandler) with(f func(slog.Handler) slog.Handler) *LeveledHandler {
+	newh := &LeveledHandler{
+		defaultHandler: f(h.defaultHandler),
+		ranges:         make([]levelRange, 0, len(h.ranges)),
 	}
-	if h.infoHandler != nil {
-		newh.infoHandler = h.infoHandler.WithGroup(name)
-	}
-	if h.warnHandler != nil {
-		newh.warnHandler = h.warnHandler.WithGroup(name)
+
+	for _, r := range h.ranges {
+		newh.ranges = append(newh.ranges, levelRange{
+			min:     r.min,
+			max:     r.max,
+			handler: f(r.handler),
+		})
 	}
 
 	return newh
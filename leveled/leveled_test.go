@@ -2,6 +2,7 @@ package leveled_test
 
 import (
 	"bytes"
+	"context"
 	"log/slog"
 	"strings"
 	"testing"
@@ -141,3 +142,56 @@ func TestWith(t *testing.T) {
 		gotwant.TestExpr(t, warnBuf.String(), strings.Contains(warnBuf.String(), "group1"))
 	})
 }
+
+func TestRange(t *testing.T) {
+	const (
+		LevelTrace  = slog.Level(-8)
+		LevelNotice = slog.Level(2)
+		LevelFatal  = slog.Level(12)
+	)
+
+	defaultBuf := bytes.Buffer{}
+	traceBuf := bytes.Buffer{}
+	warnBuf := bytes.Buffer{}
+	fatalBuf := bytes.Buffer{}
+
+	h := leveled.NewHandler(
+		slog.NewTextHandler(&defaultBuf, nil),
+		leveled.Level(LevelTrace, slog.NewTextHandler(&traceBuf, &slog.HandlerOptions{
+			Level: LevelTrace,
+		})),
+		leveled.Range(slog.LevelWarn, LevelFatal, slog.NewTextHandler(&warnBuf, nil)),
+		// overlaps the range above; registered later, so it wins.
+		leveled.Level(LevelFatal, slog.NewTextHandler(&fatalBuf, nil)),
+	)
+	l := slog.New(h)
+
+	ctx := context.Background()
+	l.Log(ctx, LevelTrace, "one")
+	l.Log(ctx, LevelNotice, "two")
+	l.Log(ctx, slog.LevelWarn+2, "three")
+	l.Log(ctx, LevelFatal, "four")
+	l.Log(ctx, slog.LevelDebug, "five")
+
+	gotwant.TestExpr(t, traceBuf.String(), strings.Contains(traceBuf.String(), "one"))
+	gotwant.TestExpr(t, defaultBuf.String(), strings.Contains(defaultBuf.String(), "two"))
+	gotwant.TestExpr(t, warnBuf.String(), strings.Contains(warnBuf.String(), "three"))
+	gotwant.TestExpr(t, fatalBuf.String(), strings.Contains(fatalBuf.String(), "four"))
+	gotwant.TestExpr(t, warnBuf.String(), !strings.Contains(warnBuf.String(), "four"))
+
+	// the default handler is Info level
+	gotwant.TestExpr(t, defaultBuf.String(), !strings.Contains(defaultBuf.String(), "five"))
+	gotwant.TestExpr(t, traceBuf.String(), !strings.Contains(traceBuf.String(), "five"))
+
+	t.Run("With", func(t *testing.T) {
+		warnBuf.Reset()
+		fatalBuf.Reset()
+
+		wl := l.With("attr1", "value1").WithGroup("group1")
+		wl.Log(ctx, slog.LevelError, "six", "attr2", "value2")
+		wl.Log(ctx, LevelFatal, "seven", "attr2", "value2")
+
+		gotwant.TestExpr(t, warnBuf.String(), strings.Contains(warnBuf.String(), "six attr1=value1 group1.attr2=value2"))
+		gotwant.TestExpr(t, fatalBuf.String(), strings.Contains(fatalBuf.String(), "seven attr1=value1 group1.attr2=value2"))
+	})
+}
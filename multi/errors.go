@@ -0,0 +1,40 @@
+package multi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HandlerError is an error returned by a handler.
+type HandlerError struct {
+	// ID is the ID of the handler (see MultiHandler.Add).
+	ID  int
+	Err error
+}
+
+func (e HandlerError) Error() string {
+	return fmt.Sprintf("handler[id=%d]: %v", e.ID, e.Err)
+}
+
+func (e HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is a list of errors returned by MultiHandler.Handle().
+type Errors []HandlerError
+
+func (e Errors) Error() string {
+	ss := make([]string, 0, len(e))
+	for _, he := range e {
+		ss = append(ss, he.Error())
+	}
+	return strings.Join(ss, "; ")
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, he := range e {
+		errs = append(errs, he)
+	}
+	return errs
+}
@@ -3,23 +3,162 @@ package multi
 import (
 	"context"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // MultiHandler duplicates its Handle() to all handlers.
+//
+// The handlers are shared by all handlers derived by WithAttrs() and WithGroup(),
+// so Add() and Remove() affect every one of them.
 type MultiHandler struct {
-	handlers []slog.Handler
+	children *children
+
+	// derived handlers apply op to handlers of parent.
+	parent *MultiHandler
+	op     func(slog.Handler) slog.Handler
+
+	cache atomic.Pointer[snapshot]
+
+	concurrent bool
+	timeout    time.Duration
+}
+
+type children struct {
+	mu      sync.Mutex
+	nextID  int
+	entries []entry
+
+	version atomic.Uint64
+}
+
+type entry struct {
+	id      int
+	handler slog.Handler
+}
+
+type snapshot struct {
+	version uint64
+	entries []entry
 }
 
 func NewHandler(handlers ...slog.Handler) *MultiHandler {
-	return &MultiHandler{
-		handlers: handlers,
+	h := &MultiHandler{
+		children: &children{},
+	}
+	for _, he := range handlers {
+		h.Add(he)
+	}
+
+	return h
+}
+
+// NewConcurrentHandler is NewHandler that calls Handle() of each handler in its own goroutine.
+//
+// If timeout > 0, a handler that does not return within timeout is reported as a context.DeadlineExceeded error.
+// It is left running in the background with a copy of the record.
+func NewConcurrentHandler(timeout time.Duration, handlers ...slog.Handler) *MultiHandler {
+	h := NewHandler(handlers...)
+	h.concurrent = true
+	h.timeout = timeout
+
+	return h
+}
+
+// Add adds a handler and returns its ID.
+//
+// Handlers derived by WithAttrs() and WithGroup() apply their attrs and groups to it.
+//
+// IDs of the handlers passed to NewHandler() are their indices.
+func (h *MultiHandler) Add(handler slog.Handler) int {
+	c := h.children
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.nextID
+	c.nextID++
+
+	if handler == nil {
+		return id
+	}
+
+	c.entries = append(c.entries[:len(c.entries):len(c.entries)], entry{id: id, handler: handler})
+	c.version.Add(1)
+
+	return id
+}
+
+// Remove removes the handler of the ID returned by Add().
+//
+// It returns false if no such handler.
+func (h *MultiHandler) Remove(id int) bool {
+	c := h.children
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, e := range c.entries {
+		if e.id == id {
+			c.entries = append(c.entries[:i:i], c.entries[i+1:]...)
+			c.version.Add(1)
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns current handlers, with attrs and groups applied.
+func (h *MultiHandler) handlers() []entry {
+	version := h.children.version.Load()
+
+	old := h.cache.Load()
+	if old != nil && old.version == version {
+		return old.entries
 	}
+
+	var s *snapshot
+	if h.parent == nil {
+		c := h.children
+		c.mu.Lock()
+		s = &snapshot{
+			version: c.version.Load(),
+			entries: c.entries,
+		}
+		c.mu.Unlock()
+	} else {
+		pentries := h.parent.handlers()
+		s = &snapshot{
+			version: version,
+			entries: make([]entry, 0, len(pentries)),
+		}
+
+	PARENT:
+		for _, pe := range pentries {
+			// reuse derived ones
+			if old != nil {
+				for _, oe := range old.entries {
+					if oe.id == pe.id {
+						s.entries = append(s.entries, oe)
+						continue PARENT
+					}
+				}
+			}
+			s.entries = append(s.entries, entry{id: pe.id, handler: h.op(pe.handler)})
+		}
+	}
+
+	h.cache.Store(s)
+
+	return s.entries
 }
 
 // returns some handler is Enabled()
 func (h *MultiHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	for _, he := range h.handlers {
-		if he != nil && he.Enabled(ctx, level) {
+	for _, e := range h.handlers() {
+		if e.handler.Enabled(ctx, level) {
 			return true
 		}
 	}
@@ -27,40 +166,122 @@ func (h *MultiHandler) Enabled(ctx context.Context, level slog.Level) bool {
 	return false
 }
 
-// applies Handle() to all handlers.
+// applies Handle() to all handlers that are Enabled() for the record.
+//
+// The returned error is Errors if some handlers failed.
 func (h *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
-	var outerErr error
-	for _, he := range h.handlers {
-		err := he.Handle(ctx, r)
-		if err != nil && outerErr == nil {
-			outerErr = err
+	if h.concurrent {
+		return h.handleConcurrently(ctx, r)
+	}
+
+	var errs Errors
+	for _, e := range h.handlers() {
+		if !e.handler.Enabled(ctx, r.Level) {
+			continue
+		}
+
+		err := e.handler.Handle(ctx, r)
+		if err != nil {
+			errs = append(errs, HandlerError{ID: e.id, Err: err})
 		}
 	}
 
-	if outerErr != nil {
-		return outerErr
+	if len(errs) != 0 {
+		return errs
 	}
 	return nil
 }
 
-// applies WithAttrs() to all handlers.
-func (h *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	newhandlers := make([]slog.Handler, 0, len(h.handlers))
+func (h *MultiHandler) handleConcurrently(ctx context.Context, r slog.Record) error {
+	entries := h.handlers()
+	results := make([]chan error, len(entries))
+
+	for i, e := range entries {
+		if !e.handler.Enabled(ctx, r.Level) {
+			continue
+		}
+
+		hctx, cancel := ctx, context.CancelFunc(func() {})
+		if h.timeout > 0 {
+			hctx, cancel = context.WithTimeout(ctx, h.timeout)
+		}
 
-	for _, he := range h.handlers {
-		newhandlers = append(newhandlers, he.WithAttrs(attrs))
+		result := make(chan error, 1)
+		results[i] = result
+
+		go func(he slog.Handler, r slog.Record) {
+			defer cancel()
+			result <- he.Handle(hctx, r)
+		}(e.handler, r.Clone())
+	}
+
+	var timer <-chan time.Time
+	if h.timeout > 0 {
+		t := time.NewTimer(h.timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	var errs Errors
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+
+		var err error
+		select {
+		case err = <-result:
+		case <-timer:
+			// every later handler has the same deadline
+			timer = closedTimer
+			select {
+			case err = <-result:
+			default:
+				err = context.DeadlineExceeded
+			}
+		}
+
+		if err != nil {
+			errs = append(errs, HandlerError{ID: entries[i].id, Err: err})
+		}
 	}
 
-	return NewHandler(newhandlers...)
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+var closedTimer = func() <-chan time.Time {
+	c := make(chan time.Time)
+	close(c)
+	return c
+}()
+
+// applies WithAttrs() to all handlers.
+func (h *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.derive(func(he slog.Handler) slog.Handler {
+		return he.WithAttrs(attrs)
+	})
 }
 
 // applies WithGroup() to all handlers.
 func (h *MultiHandler) WithGroup(name string) slog.Handler {
-	newhandlers := make([]slog.Handler, 0, len(h.handlers))
+	return h.derive(func(he slog.Handler) slog.Handler {
+		return he.WithGroup(name)
+	})
+}
 
-	for _, he := range h.handlers {
-		newhandlers = append(newhandlers, he.WithGroup(name))
+func (h *MultiHandler) derive(op func(slog.Handler) slog.Handler) *MultiHandler {
+	newh := &MultiHandler{
+		children:   h.children,
+		parent:     h,
+		op:         op,
+		concurrent: h.concurrent,
+		timeout:    h.timeout,
 	}
+	// apply op now rather than on the first Handle()
+	newh.handlers()
 
-	return NewHandler(newhandlers...)
+	return newh
 }
@@ -2,9 +2,13 @@ package multi_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/shu-go/gotwant"
 	"github.com/shu-go/shandler/multi"
@@ -27,3 +31,181 @@ func TestMulti(t *testing.T) {
 	gotwant.TestExpr(t, buf2.String(), strings.Contains(buf2.String(), "hoge"))
 	gotwant.TestExpr(t, buf3.String(), strings.Contains(buf3.String(), "hoge"))
 }
+
+type testHandler struct {
+	slog.Handler
+	delay time.Duration
+	err   error
+}
+
+func (h testHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.delay > 0 {
+		select {
+		case <-time.After(h.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if h.err != nil {
+		return h.err
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h testHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h.Handler = h.Handler.WithAttrs(attrs)
+	return h
+}
+
+func (h testHandler) WithGroup(name string) slog.Handler {
+	h.Handler = h.Handler.WithGroup(name)
+	return h
+}
+
+func TestErrors(t *testing.T) {
+	err1 := errors.New("error 1")
+	err2 := errors.New("error 2")
+
+	buf := bytes.Buffer{}
+	h := multi.NewHandler(
+		testHandler{Handler: slog.NewTextHandler(io.Discard, nil), err: err1},
+		slog.NewTextHandler(&buf, nil),
+		testHandler{Handler: slog.NewTextHandler(io.Discard, nil), err: err2},
+	)
+
+	err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "hoge", 0))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "hoge"))
+	gotwant.TestExpr(t, err, errors.Is(err, err1))
+	gotwant.TestExpr(t, err, errors.Is(err, err2))
+
+	var errs multi.Errors
+	gotwant.TestExpr(t, err, errors.As(err, &errs))
+	gotwant.Test(t, len(errs), 2)
+	gotwant.Test(t, errs[0].ID, 0)
+	gotwant.Test(t, errs[1].ID, 2)
+	gotwant.Test(t, err.Error(), "handler[id=0]: error 1; handler[id=2]: error 2")
+}
+
+func TestConcurrent(t *testing.T) {
+	fastBuf := bytes.Buffer{}
+	slowBuf := bytes.Buffer{}
+	errBuf := bytes.Buffer{}
+	errHandle := errors.New("handle")
+
+	h := multi.NewConcurrentHandler(
+		50*time.Millisecond,
+		slog.NewTextHandler(&fastBuf, nil),
+		testHandler{Handler: slog.NewTextHandler(&slowBuf, nil), delay: time.Second},
+		testHandler{Handler: slog.NewTextHandler(&errBuf, nil), err: errHandle},
+	)
+	l := slog.New(h).With("attr1", "value1")
+
+	start := time.Now()
+	err := l.Handler().Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "hoge", 0))
+	elapsed := time.Since(start)
+
+	gotwant.TestExpr(t, elapsed, elapsed < 500*time.Millisecond)
+	gotwant.TestExpr(t, fastBuf.String(), strings.Contains(fastBuf.String(), "hoge attr1=value1"))
+
+	var errs multi.Errors
+	gotwant.TestExpr(t, err, errors.As(err, &errs))
+	gotwant.Test(t, len(errs), 2)
+	gotwant.Test(t, errs[0].ID, 1)
+	gotwant.TestExpr(t, errs[0], errors.Is(errs[0], context.DeadlineExceeded))
+	gotwant.Test(t, errs[1].ID, 2)
+	gotwant.TestExpr(t, errs[1], errors.Is(errs[1], errHandle))
+}
+
+func TestMixedLevels(t *testing.T) {
+	test := func(t *testing.T, h *multi.MultiHandler, debugBuf, infoBuf, warnBuf *bytes.Buffer) {
+		l := slog.New(h).With("attr1", "value1")
+
+		l.Debug("one")
+		l.Info("two")
+		l.Warn("three")
+
+		gotwant.TestExpr(t, debugBuf.String(), strings.Contains(debugBuf.String(), "one"))
+		gotwant.TestExpr(t, debugBuf.String(), strings.Contains(debugBuf.String(), "two"))
+		gotwant.TestExpr(t, debugBuf.String(), strings.Contains(debugBuf.String(), "three"))
+
+		gotwant.TestExpr(t, infoBuf.String(), !strings.Contains(infoBuf.String(), "one"))
+		gotwant.TestExpr(t, infoBuf.String(), strings.Contains(infoBuf.String(), "two"))
+		gotwant.TestExpr(t, infoBuf.String(), strings.Contains(infoBuf.String(), "three"))
+
+		gotwant.TestExpr(t, warnBuf.String(), !strings.Contains(warnBuf.String(), "one"))
+		gotwant.TestExpr(t, warnBuf.String(), !strings.Contains(warnBuf.String(), "two"))
+		gotwant.TestExpr(t, warnBuf.String(), strings.Contains(warnBuf.String(), "three"))
+	}
+
+	t.Run("Sequential", func(t *testing.T) {
+		debugBuf := bytes.Buffer{}
+		infoBuf := bytes.Buffer{}
+		warnBuf := bytes.Buffer{}
+		h := multi.NewHandler(
+			slog.NewTextHandler(&debugBuf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			slog.NewTextHandler(&infoBuf, nil),
+			slog.NewTextHandler(&warnBuf, &slog.HandlerOptions{Level: slog.LevelWarn}),
+		)
+		test(t, h, &debugBuf, &infoBuf, &warnBuf)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		debugBuf := bytes.Buffer{}
+		infoBuf := bytes.Buffer{}
+		warnBuf := bytes.Buffer{}
+		h := multi.NewConcurrentHandler(
+			time.Second,
+			slog.NewTextHandler(&debugBuf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			slog.NewTextHandler(&infoBuf, nil),
+			slog.NewTextHandler(&warnBuf, &slog.HandlerOptions{Level: slog.LevelWarn}),
+		)
+		test(t, h, &debugBuf, &infoBuf, &warnBuf)
+	})
+}
+
+func TestAddRemove(t *testing.T) {
+	buf1 := bytes.Buffer{}
+	buf2 := bytes.Buffer{}
+	h := multi.NewHandler(
+		slog.NewTextHandler(&buf1, nil),
+	)
+	l := slog.New(h).With("attr1", "value1").WithGroup("group1")
+
+	l.Info("one", "attr2", "value2")
+
+	id := h.Add(slog.NewTextHandler(&buf2, nil))
+	gotwant.Test(t, id, 1)
+
+	l.Info("two", "attr2", "value2")
+
+	gotwant.Test(t, h.Remove(id), true)
+	gotwant.Test(t, h.Remove(id), false)
+
+	l.Info("three", "attr2", "value2")
+
+	gotwant.TestExpr(t, buf1.String(), strings.Contains(buf1.String(), "one attr1=value1 group1.attr2=value2"))
+	gotwant.TestExpr(t, buf1.String(), strings.Contains(buf1.String(), "two attr1=value1 group1.attr2=value2"))
+	gotwant.TestExpr(t, buf1.String(), strings.Contains(buf1.String(), "three attr1=value1 group1.attr2=value2"))
+
+	gotwant.TestExpr(t, buf2.String(), !strings.Contains(buf2.String(), "one"))
+	gotwant.TestExpr(t, buf2.String(), strings.Contains(buf2.String(), "two attr1=value1 group1.attr2=value2"))
+	gotwant.TestExpr(t, buf2.String(), !strings.Contains(buf2.String(), "three"))
+
+	t.Run("Concurrent", func(t *testing.T) {
+		h := multi.NewHandler(slog.NewTextHandler(io.Discard, nil))
+		l := slog.New(h).With("attr1", "value1")
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				id := h.Add(slog.NewTextHandler(io.Discard, nil))
+				h.Remove(id)
+			}
+		}()
+		for i := 0; i < 100; i++ {
+			l.Info("hoge")
+		}
+		<-done
+	})
+}
@@ -0,0 +1,206 @@
+package opt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdminHandler returns an http.Handler to view and change options of h.
+//
+//	GET           -> {"level":"INFO","source":false}
+//	PUT or POST   <- {"level":"debug","source":true,"ttl":"10m"}
+//
+// PUT and POST also accept the same names as query or form parameters (?level=WARN+2&ttl=1h).
+// A level is a name such as "debug" or "WARN+2", or a number.
+// If ttl is given, the level reverts to the one before the change after ttl
+// (the same slog.Leveler, such as *slog.LevelVar).
+// Request bodies over 64KiB are rejected.
+func AdminHandler(h *OptHandler) http.Handler {
+	return &adminHandler{
+		h: h,
+	}
+}
+
+type adminHandler struct {
+	h *OptHandler
+
+	mu        sync.Mutex
+	timer     *time.Timer
+	baseLevel slog.Leveler // the level to revert to, such as *slog.LevelVar
+	revertAt  time.Time
+}
+
+type adminStatus struct {
+	Level    string     `json:"level"`
+	Source   bool       `json:"source"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// the limit of a request body
+const maxAdminRequestSize = 64 << 10
+
+type adminRequest struct {
+	Level  json.RawMessage `json:"level"`
+	Source *bool           `json:"source"`
+	TTL    string          `json:"ttl"`
+}
+
+func (a *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		// nop
+	case http.MethodPut, http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxAdminRequestSize)
+		if err := a.change(r); err != nil {
+			code := http.StatusBadRequest
+			var mberr *http.MaxBytesError
+			if errors.As(err, &mberr) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.status())
+}
+
+func (a *adminHandler) status() adminStatus {
+	opts := a.h.options()
+
+	s := adminStatus{
+		Level:  opts.Level.Level().String(),
+		Source: opts.AddSource,
+	}
+
+	a.mu.Lock()
+	if a.timer != nil {
+		revertAt := a.revertAt
+		s.RevertAt = &revertAt
+	}
+	a.mu.Unlock()
+
+	return s
+}
+
+func (a *adminHandler) change(r *http.Request) error {
+	var req adminRequest
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		if v := r.Form.Get("level"); v != "" {
+			req.Level = json.RawMessage(strconv.Quote(v))
+		}
+		if v := r.Form.Get("source"); v != "" {
+			source, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid source %q", v)
+			}
+			req.Source = &source
+		}
+		req.TTL = r.Form.Get("ttl")
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %q", req.TTL)
+		}
+	}
+
+	// validate all before applying, not to change anything on errors
+	var level slog.Level
+	if len(req.Level) != 0 {
+		var err error
+		level, err = parseLevelJSON(req.Level)
+		if err != nil {
+			return err
+		}
+	} else if ttl != 0 {
+		return errors.New("ttl without level")
+	}
+
+	if req.Source != nil {
+		a.h.AddSource(*req.Source)
+	}
+	if len(req.Level) == 0 {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	} else if ttl != 0 {
+		a.baseLevel = a.h.options().Level
+	}
+
+	a.h.Level(level)
+
+	if ttl != 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(ttl, func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			// changed after this timer was set
+			if a.timer != timer {
+				return
+			}
+			a.h.setLeveler(a.baseLevel)
+			a.timer = nil
+		})
+		a.timer = timer
+		a.revertAt = time.Now().Add(ttl)
+	}
+
+	return nil
+}
+
+func parseLevelJSON(raw json.RawMessage) (slog.Level, error) {
+	var n int
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return slog.Level(n), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, fmt.Errorf("invalid level %s", string(raw))
+	}
+	return ParseLevel(s)
+}
+
+// ParseLevel parses a level name such as "debug", "WARN+2" or a number.
+func ParseLevel(s string) (slog.Level, error) {
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return slog.Level(n), nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid level %q", s)
+	}
+	return level, nil
+}
@@ -0,0 +1,160 @@
+package opt_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shu-go/gotwant"
+	"github.com/shu-go/shandler/opt"
+)
+
+func TestAdmin(t *testing.T) {
+	buf := bytes.Buffer{}
+	h := opt.NewTextHandler(&buf, nil)
+	l := slog.New(h).With("attr1", "value1")
+
+	srv := httptest.NewServer(opt.AdminHandler(h))
+	defer srv.Close()
+
+	do := func(t *testing.T, method, path, contentType, body string) (int, map[string]any) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		b, _ := io.ReadAll(resp.Body)
+		m := make(map[string]any)
+		json.Unmarshal(b, &m)
+		return resp.StatusCode, m
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		code, m := do(t, http.MethodGet, "/", "", "")
+		gotwant.Test(t, code, http.StatusOK)
+		gotwant.Test(t, m["level"], "INFO")
+		gotwant.Test(t, m["source"], false)
+	})
+
+	t.Run("PutJSON", func(t *testing.T) {
+		code, m := do(t, http.MethodPut, "/", "application/json", `{"level":"debug","source":true}`)
+		gotwant.Test(t, code, http.StatusOK)
+		gotwant.Test(t, m["level"], "DEBUG")
+		gotwant.Test(t, m["source"], true)
+
+		buf.Reset()
+		l.Debug("one")
+		gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "msg=one"))
+		gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "source="))
+
+		code, m = do(t, http.MethodPut, "/", "application/json", `{"level":6}`)
+		gotwant.Test(t, code, http.StatusOK)
+		gotwant.Test(t, m["level"], "WARN+2")
+	})
+
+	t.Run("PostForm", func(t *testing.T) {
+		code, m := do(t, http.MethodPost, "/?level=WARN%2B2&source=false", "", "")
+		gotwant.Test(t, code, http.StatusOK)
+		gotwant.Test(t, m["level"], "WARN+2")
+		gotwant.Test(t, m["source"], false)
+
+		code, m = do(t, http.MethodPost, "/", "application/x-www-form-urlencoded", "level=-4")
+		gotwant.Test(t, code, http.StatusOK)
+		gotwant.Test(t, m["level"], "DEBUG")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		code, _ := do(t, http.MethodPut, "/", "application/json", `{"level":"verbose"}`)
+		gotwant.Test(t, code, http.StatusBadRequest)
+
+		code, _ = do(t, http.MethodPut, "/?level=info&ttl=soon", "", "")
+		gotwant.Test(t, code, http.StatusBadRequest)
+
+		code, _ = do(t, http.MethodDelete, "/", "", "")
+		gotwant.Test(t, code, http.StatusMethodNotAllowed)
+
+		// nothing changes on errors
+		h.Level(slog.LevelInfo)
+		h.AddSource(false)
+		code, _ = do(t, http.MethodPut, "/", "application/json", `{"level":"verbose","source":true}`)
+		gotwant.Test(t, code, http.StatusBadRequest)
+		code, _ = do(t, http.MethodPut, "/?source=true&ttl=1h", "", "")
+		gotwant.Test(t, code, http.StatusBadRequest)
+
+		_, m := do(t, http.MethodGet, "/", "", "")
+		gotwant.Test(t, m["level"], "INFO")
+		gotwant.Test(t, m["source"], false)
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		h.Level(slog.LevelInfo)
+
+		code, m := do(t, http.MethodPut, "/", "application/json", `{"level":"debug","ttl":"50ms"}`)
+		gotwant.Test(t, code, http.StatusOK)
+		gotwant.Test(t, m["level"], "DEBUG")
+		gotwant.TestExpr(t, m, m["revert_at"] != nil)
+
+		waitFor(t, func() bool {
+			_, m = do(t, http.MethodGet, "/", "", "")
+			return m["level"] == "INFO"
+		})
+		gotwant.TestExpr(t, m, m["revert_at"] == nil)
+	})
+
+	t.Run("TTLLevelVar", func(t *testing.T) {
+		lv := &slog.LevelVar{}
+		lh := opt.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: lv})
+		admin := opt.AdminHandler(lh)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/?level=debug&ttl=50ms", nil)
+		admin.ServeHTTP(rec, req)
+		gotwant.Test(t, rec.Code, http.StatusOK)
+
+		waitFor(t, func() bool {
+			return !lh.Enabled(context.Background(), slog.LevelDebug)
+		})
+
+		// lv is used again after the revert
+		lv.Set(slog.LevelError)
+		gotwant.TestExpr(t, lh, !lh.Enabled(context.Background(), slog.LevelWarn))
+		lv.Set(slog.LevelDebug)
+		gotwant.TestExpr(t, lh, lh.Enabled(context.Background(), slog.LevelDebug))
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		body := `{"level":"debug","ttl":"` + strings.Repeat(" ", 1<<20) + `"}`
+		code, _ := do(t, http.MethodPut, "/", "application/json", body)
+		gotwant.Test(t, code, http.StatusRequestEntityTooLarge)
+	})
+}
+
+// waits until cond returns true, or fails after a few seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
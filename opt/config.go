@@ -0,0 +1,226 @@
+package opt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is a set of options loaded by a Loader.
+//
+// Empty fields mean the defaults (INFO, no source, text).
+type Config struct {
+	// a name such as "debug", "WARN+2", or a number
+	Level string `json:"level"`
+	// "true" or "false"
+	Source string `json:"source"`
+	// "text", "json" or a name in Formats
+	Format string `json:"format"`
+}
+
+// Format makes a handler that writes to w.
+type Format func(w io.Writer, opts *slog.HandlerOptions) slog.Handler
+
+// Formats are Formats by names (case-insensitive) other than the built-in "text" and "json".
+//
+// For example, to accept "color":
+//
+//	opt.Formats{
+//		"color": func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+//			return color.NewHandler(w, &color.HandlerOptions{AddSource: opts.AddSource, Level: opts.Level}, nil)
+//		},
+//	}
+type Formats map[string]Format
+
+// Loader loads a Config.
+type Loader func() (Config, error)
+
+// EnvLoader loads a Config from environment variables prefix_LEVEL, prefix_SOURCE and prefix_FORMAT.
+func EnvLoader(prefix string) Loader {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	return func() (Config, error) {
+		return Config{
+			Level:  os.Getenv(prefix + "LEVEL"),
+			Source: os.Getenv(prefix + "SOURCE"),
+			Format: os.Getenv(prefix + "FORMAT"),
+		}, nil
+	}
+}
+
+// FileLoader loads a Config from a JSON file such as:
+//
+//	{"level": "debug", "source": true, "format": "json"}
+func FileLoader(path string) Loader {
+	return func() (Config, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+
+		var fc struct {
+			Level  json.RawMessage `json:"level"`
+			Source json.RawMessage `json:"source"`
+			Format string          `json:"format"`
+		}
+		if err := json.Unmarshal(b, &fc); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+
+		return Config{
+			Level:  unquoteJSON(fc.Level),
+			Source: unquoteJSON(fc.Source),
+			Format: fc.Format,
+		}, nil
+	}
+}
+
+// accepts both "debug" and -4, "true" and true.
+func unquoteJSON(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// FromEnv makes an OptHandler configured by environment variables (see EnvLoader).
+//
+// For example, FromEnv(os.Stderr, "LOG", nil) reads LOG_LEVEL, LOG_SOURCE and LOG_FORMAT.
+func FromEnv(w io.Writer, prefix string, formats Formats) (*OptHandler, error) {
+	return FromLoader(w, EnvLoader(prefix), formats)
+}
+
+// FromFile makes an OptHandler configured by a JSON file (see FileLoader).
+func FromFile(w io.Writer, path string, formats Formats) (*OptHandler, error) {
+	return FromLoader(w, FileLoader(path), formats)
+}
+
+// FromLoader makes an OptHandler configured by load.
+// Config.Format is "text", "json" or a name in formats (may be nil).
+//
+// Call Reload() or Watch() to apply changes of the Config.
+func FromLoader(w io.Writer, load Loader, formats Formats) (*OptHandler, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	newfunc, opts, err := cfg.build(w, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	h := NewHandler(newfunc, &opts)
+	h.state.w = w
+	h.state.load = load
+	h.state.formats = formats
+	h.state.config = cfg
+
+	return h, nil
+}
+
+// Reload loads the Config again and applies it if changed.
+//
+// It is an error if h is not made by FromEnv, FromFile or FromLoader.
+func (h *OptHandler) Reload() error {
+	h.state.mu.Lock()
+	load := h.state.load
+	h.state.mu.Unlock()
+
+	if load == nil {
+		return errors.New("no Loader to reload")
+	}
+
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	if cfg == h.state.config {
+		return nil
+	}
+
+	newfunc, opts, err := cfg.build(h.state.w, h.state.formats)
+	if err != nil {
+		return err
+	}
+
+	h.state.newfunc = newfunc
+	h.state.opts.Level = opts.Level
+	h.state.opts.AddSource = opts.AddSource
+	h.state.config = cfg
+	renew(h)
+
+	return nil
+}
+
+// Watch calls Reload() every interval until ctx is done.
+//
+// Errors of Reload() are passed to errfunc if not nil.
+func (h *OptHandler) Watch(ctx context.Context, interval time.Duration, errfunc func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.Reload(); err != nil && errfunc != nil {
+				errfunc(err)
+			}
+		}
+	}
+}
+
+func (c Config) build(w io.Writer, formats Formats) (NewHandlerFunc, slog.HandlerOptions, error) {
+	opts := slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}
+
+	if c.Level != "" {
+		level, err := ParseLevel(c.Level)
+		if err != nil {
+			return nil, opts, err
+		}
+		opts.Level = level
+	}
+
+	if c.Source != "" {
+		source, err := strconv.ParseBool(strings.TrimSpace(c.Source))
+		if err != nil {
+			return nil, opts, fmt.Errorf("invalid source %q", c.Source)
+		}
+		opts.AddSource = source
+	}
+
+	name := strings.ToLower(strings.TrimSpace(c.Format))
+	switch name {
+	case "", "text":
+		return textHandler(w), opts, nil
+	case "json":
+		return jsonHandler(w), opts, nil
+	}
+
+	for n, f := range formats {
+		if strings.ToLower(n) == name {
+			return func(opts *slog.HandlerOptions) slog.Handler {
+				return f(w, opts)
+			}, opts, nil
+		}
+	}
+	return nil, opts, fmt.Errorf("invalid format %q", c.Format)
+}
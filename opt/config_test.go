@@ -0,0 +1,131 @@
+package opt_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shu-go/gotwant"
+	"github.com/shu-go/shandler/color"
+	"github.com/shu-go/shandler/opt"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_SOURCE", "")
+	t.Setenv("LOG_FORMAT", "json")
+
+	buf := bytes.Buffer{}
+	h, err := opt.FromEnv(&buf, "LOG", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(h).With("attr1", "value1")
+
+	l.Debug("one")
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), `"msg":"one","attr1":"value1"`))
+
+	t.Setenv("LOG_LEVEL", "WARN")
+	t.Setenv("LOG_SOURCE", "true")
+	t.Setenv("LOG_FORMAT", "text")
+	gotwant.TestError(t, h.Reload(), nil)
+
+	buf.Reset()
+	l.Info("two")
+	l.Warn("three")
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "two"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "msg=three attr1=value1"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "source="))
+
+	t.Run("Error", func(t *testing.T) {
+		t.Setenv("LOG_FORMAT", "xml")
+		err := h.Reload()
+		gotwant.TestExpr(t, err, err != nil)
+
+		_, err = opt.FromEnv(&buf, "LOG", nil)
+		gotwant.TestExpr(t, err, err != nil)
+
+		err = opt.NewTextHandler(&buf, nil).Reload()
+		gotwant.TestExpr(t, err, err != nil)
+	})
+
+	t.Run("Formats", func(t *testing.T) {
+		formats := opt.Formats{
+			"Color": func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+				return color.NewHandler(w, &color.HandlerOptions{
+					AddSource:  opts.AddSource,
+					Level:      opts.Level,
+					TimeFormat: color.TimeNone,
+				}, color.DefaultNilScheme())
+			},
+		}
+
+		t.Setenv("LOG_LEVEL", "")
+		t.Setenv("LOG_SOURCE", "")
+		t.Setenv("LOG_FORMAT", "color")
+		buf := bytes.Buffer{}
+		h, err := opt.FromEnv(&buf, "LOG", formats)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slog.New(h).Info("one", "a", 1)
+		gotwant.Test(t, buf.String(), "INFO one a=1\n")
+
+		_, err = opt.FromEnv(&buf, "LOG", nil)
+		gotwant.TestExpr(t, err, err != nil)
+	})
+}
+
+func TestFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.json")
+	write := func(content string) {
+		// rename so that Watch never reads a partial file
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"level": "info"}`)
+
+	buf := bytes.Buffer{}
+	h, err := opt.FromFile(&buf, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Watch(ctx, 10*time.Millisecond, func(err error) {
+			t.Error(err)
+		})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	l.Debug("one")
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "one"))
+
+	write(`{"level": -4, "source": true}`)
+	waitFor(t, func() bool {
+		return l.Enabled(ctx, slog.LevelDebug)
+	})
+
+	l.Debug("two")
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "msg=two"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "source="))
+}
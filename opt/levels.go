@@ -0,0 +1,95 @@
+package opt
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// a level override.
+type levelRule struct {
+	// group path such as "db.query", or ""
+	group string
+	// attr key and value, or ""
+	key, value string
+
+	level slog.Level
+}
+
+func (r levelRule) match(groups []string, attrs []slog.Attr) bool {
+	if r.group != "" {
+		path := strings.Join(groups, ".")
+		return path == r.group || strings.HasPrefix(path, r.group+".")
+	}
+
+	for _, a := range attrs {
+		if a.Key == r.key && a.Value.Resolve().String() == r.value {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupLevel overrides the level of handlers in the group path such as "db" or "db.query",
+// that is, handlers derived by WithGroup("db") and their descendants.
+func (h *OptHandler) GroupLevel(group string, level slog.Level) {
+	h.setRule(levelRule{group: group, level: level})
+}
+
+// AttrLevel overrides the level of handlers that have the attribute key=value given by WithAttrs(),
+// such as loggers made by slog.With("logger", "payments").
+func (h *OptHandler) AttrLevel(key, value string, level slog.Level) {
+	h.setRule(levelRule{key: key, value: value, level: level})
+}
+
+// ClearLevels removes all overrides by GroupLevel() and AttrLevel().
+func (h *OptHandler) ClearLevels() {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	h.state.rules = nil
+	renew(h)
+}
+
+// When some overrides are matched, the last one set wins.
+func (h *OptHandler) setRule(rule levelRule) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	rules := slices.Clone(h.state.rules)
+	rules = slices.DeleteFunc(rules, func(r levelRule) bool {
+		return r.group == rule.group && r.key == rule.key && r.value == rule.value
+	})
+	h.state.rules = append(rules, rule)
+	renew(h)
+}
+
+// returns the level of the last matched rule, or global.
+// nil if no rules.
+func (h *OptHandler) ruleLevel(rules []levelRule, global slog.Leveler) slog.Leveler {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(h.groups, h.attrs) {
+			return rules[i].level
+		}
+	}
+	return global
+}
+
+// the lowest level of the global and the rules,
+// so that the inner handler passes records to be filtered by rules.
+type minLeveler struct {
+	global slog.Leveler
+	rules  []levelRule
+}
+
+func (l minLeveler) Level() slog.Level {
+	level := l.global.Level()
+	for _, r := range l.rules {
+		level = min(level, r.level)
+	}
+	return level
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 )
 
 type NewHandlerFunc func(*slog.HandlerOptions) slog.Handler
@@ -32,88 +34,203 @@ func NewJSONHandler(w io.Writer, opts *slog.HandlerOptions) *OptHandler {
 }
 
 // OptHandler is a wrapper handler that has some change-options methods.
+//
+// Options are shared by the handlers derived by WithAttrs() and WithGroup(),
+// so changing them affects every one of them.
+//
+// Enabled() and Handle() do not lock unless options have been changed.
 type OptHandler struct {
-	inner slog.Handler
+	state *optState
 
-	newfunc NewHandlerFunc
+	// derived handlers apply op to the inner handler of parent.
+	parent *OptHandler
+	op     func(slog.Handler) slog.Handler
+
+	// given to ancestors, for level rules
+	groups []string
+	attrs  []slog.Attr
 
-	mu   *sync.Mutex
-	opts slog.HandlerOptions
+	// rebuilt when its gen != state.gen
+	inner atomic.Pointer[innerHandler]
+}
+
+type innerHandler struct {
+	slog.Handler
+	gen uint64
+
+	rules  []levelRule
+	global slog.Leveler
+	level  slog.Leveler // nil if no rules
+}
+
+type optState struct {
+	mu      sync.Mutex
+	newfunc NewHandlerFunc
+	opts    slog.HandlerOptions
+	gen     atomic.Uint64
+	rules   []levelRule
+
+	// set by FromLoader
+	w       io.Writer
+	load    Loader
+	formats Formats
+	config  Config
 }
 
 func NewHandler(newfunc NewHandlerFunc, opts *slog.HandlerOptions) *OptHandler {
 	h := &OptHandler{
-		inner:   newfunc(opts),
-		newfunc: newfunc,
-		mu:      &sync.Mutex{},
+		state: &optState{
+			newfunc: newfunc,
+		},
 	}
 	if opts != nil {
-		h.opts = *opts
+		h.state.opts = *opts
 	}
-	if h.opts.Level == nil {
-		h.opts.Level = slog.LevelInfo
+	if h.state.opts.Level == nil {
+		h.state.opts.Level = slog.LevelInfo
 	}
+	h.handler()
 
 	return h
 }
 
 func (h *OptHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.inner.Enabled(ctx, level)
+	inner := h.handler()
+	if inner.level != nil && level < inner.level.Level() {
+		return false
+	}
+	return inner.Enabled(ctx, level)
 }
 
 func (h *OptHandler) Handle(ctx context.Context, r slog.Record) error {
-	return h.inner.Handle(ctx, r)
+	inner := h.handler()
+	if inner.level != nil && r.Level < inner.level.Level() {
+		return nil
+	}
+	return inner.Handle(ctx, r)
 }
 
 func (h *OptHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	newh := h.derive(func(inner slog.Handler) slog.Handler {
+		return inner.WithAttrs(attrs)
+	})
+	newh.attrs = append(slices.Clip(h.attrs), attrs...)
+	newh.handler()
 
-	return &OptHandler{
-		inner:   h.inner.WithAttrs(attrs),
-		newfunc: h.newfunc,
-		opts:    h.opts,
-		mu:      h.mu,
-	}
+	return newh
 }
 
 func (h *OptHandler) WithGroup(name string) slog.Handler {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	newh := h.derive(func(inner slog.Handler) slog.Handler {
+		return inner.WithGroup(name)
+	})
+	newh.groups = append(slices.Clip(h.groups), name)
+	newh.handler()
+
+	return newh
+}
 
+func (h *OptHandler) derive(op func(slog.Handler) slog.Handler) *OptHandler {
 	return &OptHandler{
-		inner:   h.inner.WithGroup(name),
-		newfunc: h.newfunc,
-		opts:    h.opts,
-		mu:      h.mu,
+		state:  h.state,
+		parent: h,
+		op:     op,
+		groups: h.groups,
+		attrs:  h.attrs,
 	}
 }
 
+// returns the inner handler.
+//
+// It is rebuilt if options have been changed,
+// replaying WithAttrs() and WithGroup() of ancestors.
+func (h *OptHandler) handler() *innerHandler {
+	cur := h.inner.Load()
+	if cur != nil && cur.gen == h.state.gen.Load() {
+		return cur
+	}
+
+	var newinner *innerHandler
+	if h.parent == nil {
+		h.state.mu.Lock()
+		newfunc := h.state.newfunc
+		opts := h.state.opts
+		gen := h.state.gen.Load()
+		rules := h.state.rules
+		h.state.mu.Unlock()
+
+		global := opts.Level
+		if len(rules) != 0 {
+			// let rules decide
+			opts.Level = minLeveler{global: global, rules: rules}
+		}
+
+		newinner = &innerHandler{
+			Handler: newfunc(&opts),
+			gen:     gen,
+			rules:   rules,
+			global:  global,
+		}
+		newinner.level = h.ruleLevel(rules, global)
+	} else {
+		pinner := h.parent.handler()
+		newinner = &innerHandler{
+			Handler: h.op(pinner.Handler),
+			gen:     pinner.gen,
+			rules:   pinner.rules,
+			global:  pinner.global,
+		}
+		newinner.level = h.ruleLevel(pinner.rules, pinner.global)
+	}
+
+	// do not overwrite a newer one built concurrently
+	for {
+		if cur != nil && cur.gen >= newinner.gen {
+			return cur
+		}
+		if h.inner.CompareAndSwap(cur, newinner) {
+			return newinner
+		}
+		cur = h.inner.Load()
+	}
+}
+
+func (h *OptHandler) options() slog.HandlerOptions {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	return h.state.opts
+}
+
 func renew(h *OptHandler) {
-	opts := h.opts
-	h.inner = h.newfunc(&opts)
+	h.state.gen.Add(1)
 }
 
 func (h *OptHandler) AddSource(addSource bool) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
 
-	h.opts.AddSource = addSource
+	h.state.opts.AddSource = addSource
 	renew(h)
 }
 
 func (h *OptHandler) Level(level slog.Level) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	h.setLeveler(level)
+}
+
+// sets level as it is, such as *slog.LevelVar.
+func (h *OptHandler) setLeveler(level slog.Leveler) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
 
-	h.opts.Level = level
+	h.state.opts.Level = level
 	renew(h)
 }
 
 func (h *OptHandler) ReplaceAttr(replaceAttrr func(groups []string, a slog.Attr) slog.Attr) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
 
-	h.opts.ReplaceAttr = replaceAttrr
+	h.state.opts.ReplaceAttr = replaceAttrr
 	renew(h)
 }
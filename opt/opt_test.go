@@ -1,10 +1,15 @@
 package opt_test
 
 import (
+	"bytes"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/shu-go/gotwant"
 	"github.com/shu-go/shandler/opt"
 )
 
@@ -18,3 +23,111 @@ func TestTarget(t *testing.T) {
 	h.Level(slog.LevelInfo)
 	slog.Debug("three")
 }
+
+func TestDerived(t *testing.T) {
+	buf := bytes.Buffer{}
+	h := opt.NewTextHandler(&buf, nil)
+	l := slog.New(h).With("attr1", "value1").WithGroup("group1").With("attr2", "value2")
+
+	l.Debug("one")
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "one"))
+
+	h.Level(slog.LevelDebug)
+	l.Debug("two", "attr3", "value3")
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "msg=two attr1=value1 group1.attr2=value2 group1.attr3=value3"))
+
+	h.AddSource(true)
+	l.Debug("three")
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "source="))
+
+	// from a derived handler
+	l.Handler().(*opt.OptHandler).Level(slog.LevelInfo)
+	buf.Reset()
+	slog.New(h).Debug("four")
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "four"))
+}
+
+func TestRace(t *testing.T) {
+	h := opt.NewTextHandler(io.Discard, nil)
+	l := slog.New(h).With("attr1", "value1")
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			gl := l.WithGroup("group1")
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				gl.Debug("debug", "i", i)
+				gl.Info("info", "i", i)
+			}
+		}(i)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if i%2 == 0 {
+			h.Level(slog.LevelDebug)
+		} else {
+			h.Level(slog.LevelInfo)
+		}
+		h.AddSource(i%3 == 0)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestLevelRules(t *testing.T) {
+	buf := bytes.Buffer{}
+	h := opt.NewTextHandler(&buf, nil)
+	l := slog.New(h)
+	db := l.WithGroup("db")
+	query := db.WithGroup("query")
+	payments := l.With("logger", "payments")
+	orders := l.With("logger", "orders")
+
+	h.GroupLevel("db", slog.LevelDebug)
+	h.AttrLevel("logger", "payments", slog.LevelDebug)
+
+	l.Debug("one")
+	db.Debug("two")
+	query.Debug("three")
+	payments.Debug("four")
+	orders.Debug("five")
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "one"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "two"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "three"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "four"))
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "five"))
+
+	// the last one wins
+	h.GroupLevel("db.query", slog.LevelWarn)
+	buf.Reset()
+	db.Debug("six")
+	query.Info("seven")
+	query.Warn("eight")
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "six"))
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "seven"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "eight"))
+
+	// global level is still effective
+	h.Level(slog.LevelError)
+	buf.Reset()
+	l.Warn("nine")
+	payments.Debug("ten")
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "nine"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "ten"))
+
+	h.ClearLevels()
+	buf.Reset()
+	db.Warn("eleven")
+	payments.Error("twelve")
+	gotwant.TestExpr(t, buf.String(), !strings.Contains(buf.String(), "eleven"))
+	gotwant.TestExpr(t, buf.String(), strings.Contains(buf.String(), "twelve"))
+}
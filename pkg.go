@@ -4,4 +4,6 @@
 //   - [github.com/shu-go/shandler/mult]
 //   - [github.com/shu-go/shandler/opt]
 //   - [github.com/shu-go/shandler/color]
+//
+// cmd/ansi2html converts colored logs to HTML or plain text.
 package shandler
@@ -4,6 +4,7 @@ package testing
 import (
 	"bytes"
 	"encoding/json"
+	"strconv"
 	"strings"
 	gotesting "testing"
 
@@ -61,7 +62,7 @@ func ParseTextLogs(t *gotesting.T, in []byte, show bool) []map[string]any {
 		for {
 			var key, value []byte
 			line, key, value = nextTextComponent(line)
-			if len(key) == 0 || len(value) == 0 {
+			if len(key) == 0 {
 				break
 			}
 			m[string(key)] = string(value)
@@ -75,41 +76,48 @@ func ParseTextLogs(t *gotesting.T, in []byte, show bool) []map[string]any {
 	return ms
 }
 
+// returns the first key=value in b, unquoted.
 func nextTextComponent(b []byte) (remaining, key, value []byte) {
+	b = bytes.TrimLeft(b, " ")
+
 	// key
-	eqidx := bytes.IndexByte(b, '=')
-	if eqidx == -1 {
-		return nil, nil, nil
+	var eqidx int
+	if len(b) != 0 && b[0] == '"' {
+		q, err := strconv.QuotedPrefix(string(b))
+		if err != nil || len(b) <= len(q) || b[len(q)] != '=' {
+			return nil, nil, nil
+		}
+		uq, _ := strconv.Unquote(q)
+		key = []byte(uq)
+		eqidx = len(q)
+	} else {
+		eqidx = bytes.IndexByte(b, '=')
+		if eqidx == -1 {
+			return nil, nil, nil
+		}
+		key = b[:eqidx]
 	}
 
 	// value
 	start := eqidx + 1
 	if len(b) <= start {
-		return nil, b[:eqidx], nil
+		return nil, key, nil
 	}
 	if b[start] != '"' {
 		bb := b[start:]
 		spidx := bytes.IndexByte(bb, ' ')
 		if spidx == -1 {
-			return nil, b[:eqidx], bb
+			return nil, key, bb
 		}
-		return bb[spidx+1:], b[:eqidx], bb[:spidx]
+		return bb[spidx+1:], key, bb[:spidx]
 	}
-	start++
-	for {
-		bb := b[start:]
-		qtidx := bytes.IndexByte(bb, '"')
-		btidx := bytes.IndexByte(bb, '\\')
-		if btidx == qtidx-1 {
-			start = qtidx + 1
-			continue
-		}
 
-		if qtidx == -1 {
-			return nil, b[:eqidx], b[eqidx+1:]
-		}
-		return b[start+qtidx+1:], b[:eqidx], b[eqidx+1 : start+qtidx]
+	q, err := strconv.QuotedPrefix(string(b[start:]))
+	if err != nil {
+		return nil, key, b[start:]
 	}
+	uq, _ := strconv.Unquote(q)
+	return b[start+len(q):], key, []byte(uq)
 }
 
 func group(m map[string]any) map[string]any {
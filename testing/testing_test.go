@@ -6,6 +6,7 @@ import (
 	"testing"
 	"testing/slogtest"
 
+	"github.com/shu-go/gotwant"
 	stesting "github.com/shu-go/shandler/testing"
 )
 
@@ -34,3 +35,17 @@ func TestTesting(t *testing.T) {
 		}
 	})
 }
+
+func TestParseTextLogs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := slog.New(slog.NewTextHandler(buf, nil))
+	l.Info("a message", "empty", "", "quote", `say "hi"`, "key with space", "a=b", slog.Group("g", "k", "v"))
+
+	ms := stesting.ParseTextLogs(t, buf.Bytes(), false)
+	gotwant.Test(t, len(ms), 1)
+	gotwant.Test(t, ms[0]["msg"], "a message")
+	gotwant.Test(t, ms[0]["empty"], "")
+	gotwant.Test(t, ms[0]["quote"], `say "hi"`)
+	gotwant.Test(t, ms[0]["key with space"], "a=b")
+	gotwant.Test(t, ms[0]["g"], map[string]any{"k": "v"})
+}